@@ -1,15 +1,21 @@
 // Package chimux provides an opinionated chi router factory with built-in
 // middleware for recovery, real IP, structured logging, Prometheus metrics,
-// and health checks.
+// and health checks, plus a graceful HTTP server runner.
 package chimux
 
 import (
+	"net/http"
+	"net/netip"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/meysam81/x/httputils"
 	"github.com/meysam81/x/logging"
 )
 
@@ -17,13 +23,32 @@ type Option = func(*options)
 
 type options struct {
 	disableRecoveryMiddleware bool
+	tracingMiddleware         func(http.Handler) http.Handler
 	disableCleanPath          bool
 	disableRealIP             bool
+	trustedProxies            []netip.Prefix
+
+	enableRequestID    bool
+	requestIDHeader    string
+	requestIDGenerator func() string
 
 	enableLoggingMiddleware bool
 	logger                  *logging.Logger
 	headerLogMode           headerLogMode
 	extraLogHeaders         map[string]struct{}
+	responseLogHeaders      map[string]struct{}
+
+	extraSensitiveHeaders     map[string]struct{}
+	extraSensitiveQueryParams map[string]struct{}
+	redactPatterns            []*regexp.Regexp
+	disableLogQuery           bool
+
+	logSampleRate       float64
+	logRouteSampleRates map[string]float64
+	logSlowThreshold    time.Duration
+	logSkipPathPrefixes []string
+	logSkipUserAgents   []string
+	logSkipFuncs        []func(*http.Request) bool
 
 	enableMetrics        bool
 	enableHealthz        bool
@@ -31,6 +56,39 @@ type options struct {
 	healthzEndpoint      string
 	enableMetricsLogging bool
 	metricsEndpoint      string
+
+	health            *Health
+	livenessEndpoint  string
+	readinessEndpoint string
+
+	metricsUnmatchedRouteLabel string
+	metricsOptions             metricsOptions
+
+	enableSecurityHeaders bool
+	securityHeaders       securityHeadersOptions
+	enableCORS            bool
+	cors                  cors.Options
+	maxBodySize           int64
+	rateLimit             func(http.Handler) http.Handler
+	panicRenderer         PanicRenderer
+
+	requestTimeout          time.Duration
+	routeTimeouts           map[string]time.Duration
+	maxInFlight             int
+	maxInFlightQueue        int
+	maxInFlightQueueTimeout time.Duration
+
+	enableDebugEndpoints bool
+	debugPrefix          string
+	debugAuth            DebugAuthFunc
+	debugRateLimit       func(http.Handler) http.Handler
+	adminAddr            string
+
+	enableCompression bool
+	compression       compressionOptions
+	enableETag        bool
+
+	serverOptions []httputils.ServerOption
 }
 
 func WithDisableRecoveryMiddleware() Option {
@@ -51,6 +109,31 @@ func WithDisableRealIP() Option {
 	}
 }
 
+// WithRequestID accepts the request ID sent by the client or generates one,
+// echoes it on the response, stores it in the request context for
+// RequestIDFrom and adds it to access logs as the request_id field.
+func WithRequestID() Option {
+	return func(o *options) {
+		o.enableRequestID = true
+	}
+}
+
+// WithRequestIDHeader sets the header the request ID is read from and written
+// to. Defaults to "X-Request-Id".
+func WithRequestIDHeader(header string) Option {
+	return func(o *options) {
+		o.requestIDHeader = header
+	}
+}
+
+// WithRequestIDGenerator sets the function generating request IDs, such as
+// NewULID. Defaults to NewUUIDv7.
+func WithRequestIDGenerator(fn func() string) Option {
+	return func(o *options) {
+		o.requestIDGenerator = fn
+	}
+}
+
 func WithLoggingMiddleware() Option {
 	return func(o *options) {
 		o.enableLoggingMiddleware = true
@@ -75,6 +158,64 @@ func WithMetrics() Option {
 	}
 }
 
+// WithMetricsUnmatchedRouteLabel sets the path label recorded for requests
+// that do not match any route (404 and 405 responses). Defaults to "unmatched".
+func WithMetricsUnmatchedRouteLabel(label string) Option {
+	return func(o *options) {
+		o.metricsUnmatchedRouteLabel = label
+	}
+}
+
+// WithMetricsRegistry registers the HTTP metrics with reg instead of the
+// global default registry. If reg is also a prometheus.Gatherer, such as a
+// *prometheus.Registry, the metrics endpoint serves it.
+func WithMetricsRegistry(reg prometheus.Registerer) Option {
+	return func(o *options) {
+		o.metricsOptions.registerer = reg
+	}
+}
+
+// WithMetricsGatherer sets the gatherer served by the metrics endpoint. Use it
+// when the registerer passed to WithMetricsRegistry is not itself a gatherer,
+// e.g. one wrapped with prometheus.WrapRegistererWith.
+func WithMetricsGatherer(g prometheus.Gatherer) Option {
+	return func(o *options) {
+		o.metricsOptions.gatherer = g
+	}
+}
+
+// WithMetricsNamespace prefixes every HTTP metric name with namespace,
+// e.g. "myapp_http_requests_total".
+func WithMetricsNamespace(namespace string) Option {
+	return func(o *options) {
+		o.metricsOptions.namespace = namespace
+	}
+}
+
+// WithMetricsConstLabels attaches constant labels to every HTTP metric.
+func WithMetricsConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.metricsOptions.constLabels = labels
+	}
+}
+
+// WithLatencyBuckets sets the classic histogram buckets, in seconds, of the
+// request duration metric. Defaults to prometheus.DefBuckets.
+func WithLatencyBuckets(buckets ...float64) Option {
+	return func(o *options) {
+		o.metricsOptions.latencyBuckets = buckets
+	}
+}
+
+// WithNativeHistograms records the request duration as a Prometheus native
+// histogram with the given bucket growth factor, e.g. 1.1. Classic buckets are
+// only kept alongside it when WithLatencyBuckets is also set.
+func WithNativeHistograms(bucketFactor float64) Option {
+	return func(o *options) {
+		o.metricsOptions.nativeHistogramBucketFactor = bucketFactor
+	}
+}
+
 func WithHealthz() Option {
 	return func(o *options) {
 		o.enableHealthz = true
@@ -93,6 +234,26 @@ func WithMetricsEndpoint(uri string) Option {
 	}
 }
 
+// WithHealth serves the liveness and readiness probes of h, by default on
+// "/livez" and "/readyz".
+func WithHealth(h *Health) Option {
+	return func(o *options) {
+		o.health = h
+	}
+}
+
+func WithLivenessEndpoint(uri string) Option {
+	return func(o *options) {
+		o.livenessEndpoint = uri
+	}
+}
+
+func WithReadinessEndpoint(uri string) Option {
+	return func(o *options) {
+		o.readinessEndpoint = uri
+	}
+}
+
 func WithLogHealthRequests() Option {
 	return func(o *options) {
 		o.enableHealthzLogging = true
@@ -120,23 +281,151 @@ func WithLogHeaders(headers ...string) Option {
 	}
 }
 
-// NewChi creates a chi.Mux with opinionated defaults: CleanPath, RealIP, and
-// Recoverer middleware are enabled out of the box. Use option functions to add
-// structured logging, Prometheus metrics, health checks, or disable defaults.
-func NewChi(opts ...Option) *chi.Mux {
+func newOptions(opts ...Option) *options {
 	o := &options{
 		disableRecoveryMiddleware: false,
 		enableLoggingMiddleware:   false,
+		requestIDHeader:           defaultRequestIDHeader,
+		requestIDGenerator:        NewUUIDv7,
 		headerLogMode:             headerLogDefault,
+		redactPatterns:            []*regexp.Regexp{JWTPattern},
+		logSampleRate:             1,
 		enableMetrics:             false,
 		healthzEndpoint:           "/healthz",
 		metricsEndpoint:           "/metrics",
+		livenessEndpoint:          "/livez",
+		readinessEndpoint:         "/readyz",
+
+		metricsUnmatchedRouteLabel: "unmatched",
+
+		securityHeaders: defaultSecurityHeadersOptions(),
+		cors:            defaultCORSOptions(),
+		compression:     defaultCompressionOptions(),
 	}
 
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	return o
+}
+
+// WithSensitiveHeaders adds headers whose values are masked in logs, on top
+// of the built-in set (Authorization, Cookie, X-Api-Key, ...).
+// Header names are case-insensitive.
+func WithSensitiveHeaders(headers ...string) Option {
+	return func(o *options) {
+		if o.extraSensitiveHeaders == nil {
+			o.extraSensitiveHeaders = make(map[string]struct{})
+		}
+		for _, h := range headers {
+			o.extraSensitiveHeaders[strings.ToLower(h)] = struct{}{}
+		}
+	}
+}
+
+// WithSensitiveQueryParams adds query parameters whose values are masked in
+// logs, on top of the built-in set (token, access_token, password, ...).
+// Parameter names are case-insensitive.
+func WithSensitiveQueryParams(params ...string) Option {
+	return func(o *options) {
+		if o.extraSensitiveQueryParams == nil {
+			o.extraSensitiveQueryParams = make(map[string]struct{})
+		}
+		for _, p := range params {
+			o.extraSensitiveQueryParams[strings.ToLower(p)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactPatterns masks every match of patterns in logged header and
+// query values, in addition to the default JWTPattern.
+func WithRedactPatterns(patterns ...*regexp.Regexp) Option {
+	return func(o *options) {
+		o.redactPatterns = append(o.redactPatterns, patterns...)
+	}
+}
+
+// WithDisableLogQuery omits the query parameters from the access log.
+func WithDisableLogQuery() Option {
+	return func(o *options) {
+		o.disableLogQuery = true
+	}
+}
+
+// WithLogResponseHeaders logs the given response headers under the
+// response_headers field. Sensitive headers such as Set-Cookie are masked.
+// Header names are case-insensitive.
+func WithLogResponseHeaders(headers ...string) Option {
+	return func(o *options) {
+		if o.responseLogHeaders == nil {
+			o.responseLogHeaders = make(map[string]struct{})
+		}
+		for _, h := range headers {
+			o.responseLogHeaders[strings.ToLower(h)] = struct{}{}
+		}
+	}
+}
+
+// WithLogSampleRate logs only the given fraction, between 0 and 1, of
+// successful requests. Requests answered with 4xx/5xx and slow requests (see
+// WithLogSlowThreshold) are always logged. Defaults to 1.
+func WithLogSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.logSampleRate = rate
+	}
+}
+
+// WithLogRouteSampleRate overrides the sample rate of successful requests for
+// a chi route pattern such as "/users/{id}".
+func WithLogRouteSampleRate(pattern string, rate float64) Option {
+	return func(o *options) {
+		if o.logRouteSampleRates == nil {
+			o.logRouteSampleRates = make(map[string]float64)
+		}
+		o.logRouteSampleRates[pattern] = rate
+	}
+}
+
+// WithLogSlowThreshold always logs requests that take at least d, regardless
+// of sampling, and marks them with slow=true.
+func WithLogSlowThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.logSlowThreshold = d
+	}
+}
+
+// WithLogSkipPathPrefixes never logs requests whose path starts with one of
+// prefixes, e.g. "/static/".
+func WithLogSkipPathPrefixes(prefixes ...string) Option {
+	return func(o *options) {
+		o.logSkipPathPrefixes = append(o.logSkipPathPrefixes, prefixes...)
+	}
+}
+
+// WithLogSkipUserAgents never logs requests whose User-Agent contains one of
+// substrings, e.g. "kube-probe". Matching is case-insensitive.
+func WithLogSkipUserAgents(substrings ...string) Option {
+	return func(o *options) {
+		for _, s := range substrings {
+			o.logSkipUserAgents = append(o.logSkipUserAgents, strings.ToLower(s))
+		}
+	}
+}
+
+// WithLogSkip never logs requests for which fn returns true.
+func WithLogSkip(fn func(r *http.Request) bool) Option {
+	return func(o *options) {
+		o.logSkipFuncs = append(o.logSkipFuncs, fn)
+	}
+}
+
+// NewChi creates a chi.Mux with opinionated defaults: CleanPath, RealIP, and
+// Recoverer middleware are enabled out of the box. Use option functions to add
+// structured logging, Prometheus metrics, health checks, or disable defaults.
+func NewChi(opts ...Option) *chi.Mux {
+	o := newOptions(opts...)
+
 	r := chi.NewRouter()
 
 	if !o.disableCleanPath {
@@ -144,7 +433,15 @@ func NewChi(opts ...Option) *chi.Mux {
 	}
 
 	if !o.disableRealIP {
-		r.Use(middleware.RealIP)
+		if len(o.trustedProxies) > 0 {
+			r.Use((&trustedRealIP{trusted: o.trustedProxies}).middleware)
+		} else {
+			r.Use(capturePeerAddr, middleware.RealIP)
+		}
+	}
+
+	if o.enableRequestID {
+		r.Use(requestIDMiddleware(o))
 	}
 
 	if o.enableLoggingMiddleware {
@@ -156,19 +453,64 @@ func NewChi(opts ...Option) *chi.Mux {
 		r.Use(loggingMiddleware(o))
 	}
 
+	var m *metrics
+	if o.enableMetrics {
+		m = newMetrics(o)
+		r.Use(m.middleware)
+	}
+
 	if !o.disableRecoveryMiddleware {
-		r.Use(middleware.Recoverer)
+		r.Use(recoverer(o, m))
 	}
 
-	if o.enableMetrics {
-		m := newMetrics()
-		r.Use(m.middleware)
-		r.Get(o.metricsEndpoint, promhttp.Handler().ServeHTTP)
+	if o.tracingMiddleware != nil {
+		r.Use(o.tracingMiddleware, tracePanics)
+	}
+
+	if o.enableSecurityHeaders {
+		r.Use(securityHeadersMiddleware(o))
+	}
+
+	if o.enableCORS {
+		r.Use(corsMiddleware(o))
+	}
+
+	if o.maxBodySize > 0 {
+		r.Use(maxBodySizeMiddleware(o.maxBodySize))
+	}
+
+	if o.rateLimit != nil {
+		r.Use(rateLimitMiddleware(o))
+	}
+
+	if o.maxInFlight > 0 {
+		r.Use(loadShedMiddleware(o, m))
+	}
+
+	if o.requestTimeout > 0 || len(o.routeTimeouts) > 0 {
+		r.Use(timeoutMiddleware(o))
+	}
+
+	if o.enableCompression {
+		r.Use(compressionMiddleware(o))
+	}
+
+	if o.enableETag {
+		r.Use(etagMiddleware)
+	}
+
+	if o.adminAddr == "" {
+		mountAdminEndpoints(r, o)
 	}
 
 	if o.enableHealthz {
 		r.Get(o.healthzEndpoint, healthCheck)
 	}
 
+	if o.health != nil {
+		r.Get(o.livenessEndpoint, o.health.LivenessHandler().ServeHTTP)
+		r.Get(o.readinessEndpoint, o.health.ReadinessHandler().ServeHTTP)
+	}
+
 	return r
 }
@@ -0,0 +1,325 @@
+package chimux
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingZstd   = "zstd"
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+type compressionOptions struct {
+	encodings    []string
+	contentTypes []string
+	minSize      int
+}
+
+func defaultCompressionOptions() compressionOptions {
+	return compressionOptions{
+		encodings: []string{encodingZstd, encodingBrotli, encodingGzip},
+		contentTypes: []string{
+			"text/*",
+			"application/json",
+			"application/*+json",
+			"application/javascript",
+			"application/xml",
+			"application/*+xml",
+			"image/svg+xml",
+		},
+		minSize: 1024,
+	}
+}
+
+// WithCompression compresses responses with zstd, brotli or gzip, in that
+// order of preference, depending on the client's Accept-Encoding. Only
+// responses of an allowed content type and at least the minimum size are
+// compressed; responses that already carry a Content-Encoding are left alone.
+// The metrics and logging middleware count the compressed bytes.
+func WithCompression() Option {
+	return func(o *options) {
+		o.enableCompression = true
+	}
+}
+
+// WithCompressionEncodings replaces the supported encodings, most preferred
+// first. Valid encodings are "zstd", "br" and "gzip"; others are ignored.
+func WithCompressionEncodings(encodings ...string) Option {
+	return func(o *options) {
+		o.compression.encodings = encodings
+	}
+}
+
+// WithCompressionContentTypes replaces the allowlist of compressed media
+// types. Entries may use a wildcard subtype or suffix, e.g. "text/*" or
+// "application/*+json". Defaults to text, JSON, JavaScript, XML and SVG.
+func WithCompressionContentTypes(types ...string) Option {
+	return func(o *options) {
+		o.compression.contentTypes = types
+	}
+}
+
+// WithCompressionMinSize sets the smallest response body, in bytes, worth
+// compressing. Defaults to 1KB.
+func WithCompressionMinSize(n int) Option {
+	return func(o *options) {
+		o.compression.minSize = n
+	}
+}
+
+// encoder is implemented by the gzip, zstd and brotli writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type zstdEncoder struct{ *zstd.Encoder }
+
+func (e zstdEncoder) Reset(w io.Writer) { e.Encoder.Reset(w) }
+
+var encoderPools = map[string]*sync.Pool{
+	encodingGzip: {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	encodingZstd: {New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return zstdEncoder{w}
+	}},
+	encodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+}
+
+// negotiateEncoding returns the first of the supported encodings accepted by
+// the Accept-Encoding header, or an empty string.
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		accepted[name] = q > 0
+	}
+	for _, enc := range supported {
+		if ok, listed := accepted[enc]; ok || (!listed && accepted["*"]) {
+			return enc
+		}
+	}
+	return ""
+}
+
+// isCompressibleType matches a Content-Type against the allowlist.
+func isCompressibleType(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	for _, pattern := range allowed {
+		ptyp, psub, _ := strings.Cut(strings.ToLower(pattern), "/")
+		if ptyp != typ {
+			continue
+		}
+		switch {
+		case psub == "*" || psub == sub:
+			return true
+		case strings.HasPrefix(psub, "*+") && strings.HasSuffix(sub, psub[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+func compressionMiddleware(o *options) func(next http.Handler) http.Handler {
+	co := o.compression
+	co.encodings = nil
+	for _, enc := range o.compression.encodings {
+		if _, ok := encoderPools[enc]; ok {
+			co.encodings = append(co.encodings, enc)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), co.encodings)
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, opts: &co, encoding: encoding}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter buffers the start of the response until it knows whether the
+// body is worth compressing, then streams it either through an encoder or
+// unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	opts     *compressionOptions
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.opts.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(false); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide chooses between compressing and passing the response through, sends
+// the header and writes out the buffered body. A partial decision, taken when
+// the handler flushes early, ignores the minimum size.
+func (cw *compressWriter) decide(partial bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if cw.shouldCompress(partial) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			// A strong validator must differ between representations.
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) shouldCompress(partial bool) bool {
+	h := cw.Header()
+	if cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if !partial && len(cw.buf) < cw.opts.minSize {
+		return false
+	}
+	return isCompressibleType(h.Get("Content-Type"), cw.opts.contentTypes)
+}
+
+// Flush sends the buffered data, deciding on compression even if fewer than
+// the minimum bytes have been written, so that streamed responses are not
+// held back.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("chimux: response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes the response once the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// Nothing written: let net/http send its implicit 200.
+			return
+		}
+		_ = cw.decide(false)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(nil)
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
@@ -0,0 +1,209 @@
+package chimux
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meysam81/x/ratelimit"
+	"github.com/rs/zerolog"
+)
+
+// DebugAuthFunc reports whether r may access the debug endpoints.
+type DebugAuthFunc func(r *http.Request) bool
+
+// DebugBearerToken accepts requests carrying "Authorization: Bearer <token>".
+func DebugBearerToken(token string) DebugAuthFunc {
+	return func(r *http.Request) bool {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+}
+
+// WithDebugEndpoints mounts runtime debugging endpoints under prefix, e.g.
+// "/debug", which is also used when prefix is empty:
+//
+//	GET      {prefix}/pprof/      net/http/pprof profiles
+//	GET      {prefix}/vars        expvar variables
+//	GET      {prefix}/buildinfo   module and VCS information of the binary
+//	GET, PUT {prefix}/loglevel    global zerolog level, set with ?level=debug
+//
+// Requests rejected by authFunc get 403 Forbidden; NewChi panics if authFunc
+// is nil so that the endpoints cannot be exposed by accident. Use WithAdminAddr
+// to serve them on a separate listener instead of the main router. Requests
+// are rate limited before authFunc runs, by WithDebugRateLimit or else by the
+// WithRateLimit limiter, so that credentials cannot be guessed at full speed.
+//
+// The log level switch calls zerolog.SetGlobalLevel, which can only make
+// loggers less verbose than their own level; create the logger at the most
+// verbose level that should be reachable at runtime and lower the global
+// level at startup.
+func WithDebugEndpoints(prefix string, authFunc DebugAuthFunc) Option {
+	return func(o *options) {
+		o.enableDebugEndpoints = true
+		o.debugPrefix = strings.TrimSuffix(prefix, "/")
+		if o.debugPrefix == "" {
+			o.debugPrefix = "/debug"
+		}
+		o.debugAuth = authFunc
+	}
+}
+
+// WithDebugRateLimit gives the WithDebugEndpoints routes their own limit,
+// checked before the auth function on the main router and on the admin
+// listener alike. Without it they share the WithRateLimit limiter. See
+// RateLimit for the available options.
+func WithDebugRateLimit(rl *ratelimit.RateLimit, opts ...RateLimitOption) Option {
+	return func(o *options) {
+		o.debugRateLimit = RateLimit(rl, opts...)
+	}
+}
+
+// WithAdminAddr moves the metrics and debug endpoints off the main router to
+// a separate listener on addr, e.g. "127.0.0.1:9090", started by Serve. Use
+// AdminHandler to serve them yourself.
+func WithAdminAddr(addr string) Option {
+	return func(o *options) {
+		o.adminAddr = addr
+	}
+}
+
+// AdminHandler returns a router serving the metrics and debug endpoints
+// enabled by opts, for use with WithAdminAddr when not running Serve.
+func AdminHandler(opts ...Option) http.Handler {
+	o := newOptions(opts...)
+	r := chi.NewRouter()
+	mountAdminEndpoints(r, o)
+	return r
+}
+
+func mountAdminEndpoints(r chi.Router, o *options) {
+	if o.enableMetrics {
+		r.Get(o.metricsEndpoint, o.metricsOptions.handler().ServeHTTP)
+	}
+	if o.enableDebugEndpoints {
+		mountDebugEndpoints(r, o)
+	}
+}
+
+func mountDebugEndpoints(r chi.Router, o *options) {
+	if o.debugAuth == nil {
+		panic("chimux: WithDebugEndpoints requires an auth function")
+	}
+	auth := o.debugAuth
+
+	limit := o.debugRateLimit
+	if limit == nil {
+		limit = o.rateLimit
+	}
+
+	r.Route(o.debugPrefix, func(r chi.Router) {
+		if limit != nil {
+			r.Use(limit)
+		}
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !auth(r) {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+
+		// CleanPath strips the trailing slash from the routing path, so the
+		// index is routed on both; its relative links need the slash.
+		index := func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+			pprof.Index(w, r)
+		}
+		r.Get("/pprof", index)
+		r.Get("/pprof/", index)
+		r.Get("/pprof/cmdline", pprof.Cmdline)
+		r.Get("/pprof/profile", pprof.Profile)
+		r.Post("/pprof/symbol", pprof.Symbol)
+		r.Get("/pprof/symbol", pprof.Symbol)
+		r.Get("/pprof/trace", pprof.Trace)
+		// pprof.Index only resolves named profiles below /debug/pprof/.
+		r.Get("/pprof/{profile}", func(w http.ResponseWriter, r *http.Request) {
+			pprof.Handler(chi.URLParam(r, "profile")).ServeHTTP(w, r)
+		})
+
+		r.Get("/vars", expvar.Handler().ServeHTTP)
+		r.Get("/buildinfo", buildInfoHandler)
+		r.Get("/loglevel", logLevelHandler)
+		r.Put("/loglevel", logLevelHandler)
+	})
+}
+
+type buildInfo struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Main      string            `json:"main"`
+	Settings  map[string]string `json:"settings"`
+	Deps      []string          `json:"deps"`
+}
+
+func buildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info not available", http.StatusNotFound)
+		return
+	}
+
+	resp := buildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Path,
+		Main:      info.Main.Path + "@" + info.Main.Version,
+		Settings:  make(map[string]string, len(info.Settings)),
+		Deps:      make([]string, 0, len(info.Deps)),
+	}
+	for _, s := range info.Settings {
+		resp.Settings[s.Key] = s.Value
+	}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		resp.Deps = append(resp.Deps, dep.Path+"@"+dep.Version)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// logLevelHandler reports the global log level and, on PUT, replaces it with
+// the level given in the "level" query parameter or the request body.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		value := r.URL.Query().Get("level")
+		if value == "" {
+			body, _ := io.ReadAll(io.LimitReader(r.Body, 64))
+			value = strings.TrimSpace(string(body))
+		}
+		level, err := zerolog.ParseLevel(strings.ToLower(value))
+		if err != nil || value == "" {
+			http.Error(w, "invalid log level", http.StatusBadRequest)
+			return
+		}
+		zerolog.SetGlobalLevel(level)
+		LoggerFrom(r.Context()).Log().Str("level", level.String()).Msg("global log level changed")
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"level": zerolog.GlobalLevel().String()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
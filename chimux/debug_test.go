@@ -0,0 +1,136 @@
+package chimux
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/meysam81/x/ratelimit"
+	"github.com/meysam81/x/ratelimit/ratelimittest"
+	"github.com/rs/zerolog"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	r := NewChi(WithDebugEndpoints("/debug", DebugBearerToken("secret")))
+
+	do := func(method, path string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine", "/debug/vars", "/debug/buildinfo", "/debug/loglevel"} {
+		if w := do("GET", path, false); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s without token: status = %d, want %d", path, w.Code, http.StatusForbidden)
+		}
+		if w := do("GET", path, true); w.Code != http.StatusOK {
+			t.Errorf("GET %s: status = %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+
+	t.Cleanup(func() { zerolog.SetGlobalLevel(zerolog.TraceLevel) })
+	w := do("PUT", "/debug/loglevel?level=warn", true)
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["level"] != "warn" || zerolog.GlobalLevel() != zerolog.WarnLevel {
+		t.Errorf("log level = %q (global %s), want warn", resp["level"], zerolog.GlobalLevel())
+	}
+	if w := do("PUT", "/debug/loglevel?level=loud", true); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid level: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	t.Run("nil auth func", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("NewChi did not panic")
+			}
+		}()
+		NewChi(WithDebugEndpoints("/debug", nil))
+	})
+}
+
+func TestDebugEndpointsRateLimit(t *testing.T) {
+	shared, _ := ratelimittest.New(t, 2)
+	own, _ := ratelimittest.New(t, 2)
+	fixed := WithRateLimitAlgorithm(ratelimit.AlgorithmFixedWindow)
+	debug := WithDebugEndpoints("/debug", DebugBearerToken("secret"))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{"main router shares WithRateLimit", NewChi(debug, WithRateLimit(shared, fixed))},
+		{"admin handler with its own limit", AdminHandler(debug, WithDebugRateLimit(own, fixed))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := make([]int, 3)
+			for i := range codes {
+				req := httptest.NewRequest("GET", "/debug/vars", nil)
+				req.Header.Set("Authorization", "Bearer guess")
+				w := httptest.NewRecorder()
+				tt.handler.ServeHTTP(w, req)
+				codes[i] = w.Code
+			}
+			if codes[0] != http.StatusForbidden || codes[1] != http.StatusForbidden || codes[2] != http.StatusTooManyRequests {
+				t.Errorf("status codes = %v, want two 403s then 429", codes)
+			}
+		})
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminAddr := ln.Addr().String()
+	_ = ln.Close()
+
+	opts := []Option{WithMetrics(), WithAdminAddr(adminAddr), WithDebugEndpoints("/debug", func(*http.Request) bool { return true })}
+	r := NewChi(opts...)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("main router serves metrics: status = %d", w.Code)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, r, append(opts, WithAddr("127.0.0.1:0"), WithDrainDelay(0))...)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	})
+
+	var resp *http.Response
+	for range 50 {
+		resp, err = http.Get("http://" + adminAddr + "/debug/buildinfo")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("admin buildinfo: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,162 @@
+package chimux
+
+import (
+	"bufio"
+	"errors"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxETagBodySize bounds the response body buffered to compute an ETag.
+// Larger responses are streamed without one.
+const maxETagBodySize = 4 << 20
+
+// WithETag adds a weak ETag, derived from a hash of the body, to successful
+// GET responses that do not set one, and answers requests whose If-None-Match
+// matches the ETag with 304 Not Modified. The response is buffered to compute
+// the hash, so streamed responses, which flush early, and bodies over 4MB are
+// sent without an ETag. HEAD responses have no body to hash and are left
+// alone rather than given an ETag that differs from GET's.
+func WithETag() Option {
+	return func(o *options) {
+		o.enableETag = true
+	}
+}
+
+func etagMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ew := &etagWriter{ResponseWriter: w, r: r}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// etagWriter buffers a response until the handler returns, then sends it with
+// an ETag or replaces it with 304 Not Modified.
+type etagWriter struct {
+	http.ResponseWriter
+	r *http.Request
+
+	status      int
+	buf         []byte
+	passthrough bool
+}
+
+func (ew *etagWriter) WriteHeader(status int) {
+	if ew.passthrough {
+		ew.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if status >= 100 && status < 200 {
+		ew.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if ew.status == 0 {
+		ew.status = status
+	}
+}
+
+func (ew *etagWriter) Write(p []byte) (int, error) {
+	if ew.passthrough {
+		return ew.ResponseWriter.Write(p)
+	}
+	if ew.status == 0 {
+		ew.status = http.StatusOK
+	}
+	if len(ew.buf)+len(p) > maxETagBodySize {
+		if err := ew.stream(); err != nil {
+			return 0, err
+		}
+		return ew.ResponseWriter.Write(p)
+	}
+	ew.buf = append(ew.buf, p...)
+	return len(p), nil
+}
+
+// stream gives up on the ETag and sends what has been buffered so far.
+func (ew *etagWriter) stream() error {
+	ew.passthrough = true
+	if ew.status == 0 {
+		return nil
+	}
+	ew.ResponseWriter.WriteHeader(ew.status)
+	buf := ew.buf
+	ew.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := ew.ResponseWriter.Write(buf)
+	return err
+}
+
+func (ew *etagWriter) Flush() {
+	if !ew.passthrough {
+		_ = ew.stream()
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (ew *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := ew.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("chimux: response writer does not support hijacking")
+	}
+	ew.passthrough = true
+	return h.Hijack()
+}
+
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+func (ew *etagWriter) finish() {
+	if ew.passthrough || ew.status == 0 {
+		return
+	}
+
+	h := ew.Header()
+	if ew.status == http.StatusOK {
+		etag := h.Get("ETag")
+		if etag == "" {
+			hash := fnv.New64a()
+			_, _ = hash.Write(ew.buf)
+			etag = `W/"` + strconv.FormatUint(hash.Sum64(), 36) + `"`
+			h.Set("ETag", etag)
+		}
+		if etagMatches(ew.r.Header.Get("If-None-Match"), etag) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			ew.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	_ = ew.stream()
+}
+
+// etagMatches applies the weak comparison required for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package chimux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	body := strings.Repeat("hello world ", 200)
+
+	r := NewChi(WithETag(), WithCompression())
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, body)
+	})
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("ETag = %q, want a weak validator", etag)
+	}
+	if w.Body.String() != body {
+		t.Error("body does not match")
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		for _, encoding := range []string{"", "gzip"} {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("If-None-Match", `"other", `+etag)
+			req.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusNotModified {
+				t.Errorf("Accept-Encoding %q: status = %d, want %d", encoding, w.Code, http.StatusNotModified)
+			}
+			if w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" {
+				t.Errorf("Accept-Encoding %q: 304 has a body or encoding", encoding)
+			}
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", `W/"stale"`)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Errorf("status = %d, want %d with full body", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		head := NewChi(WithETag())
+		head.Head("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		head.ServeHTTP(w, httptest.NewRequest("HEAD", "/", nil))
+		if got := w.Header().Get("ETag"); got != "" || w.Code != http.StatusOK {
+			t.Errorf("HEAD = %d with ETag %q, want 200 without one", w.Code, got)
+		}
+	})
+
+	t.Run("unsafe methods", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+		if got := w.Header().Get("ETag"); got != "" {
+			t.Errorf("POST ETag = %q, want none", got)
+		}
+	})
+}
@@ -1,9 +1,12 @@
 package chimux
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/meysam81/x/httputils"
 )
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
@@ -11,3 +14,68 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = fmt.Fprintf(w, `{"status":"healthy","timestamp":"%s"}`, time.Now().UTC().Format(time.RFC3339))
 }
+
+// Health is a registry of named dependency checks served as separate
+// liveness and readiness probes. Register checks at startup, then pass the
+// registry to NewChi with WithHealth:
+//
+//	h := chimux.NewHealth()
+//	h.Register("sqlite", chimux.PingCheck(db))
+//	h.Register("redis", rl.Ping, chimux.WithCheckNonCritical())
+//	r := chimux.NewChi(chimux.WithHealth(h))
+//
+// It is shared with the gin package through httputils.Health.
+type Health = httputils.Health
+
+// CheckFunc reports whether a dependency is usable. It must honor ctx
+// cancellation, which carries the check timeout.
+type CheckFunc = httputils.CheckFunc
+
+// CheckOption configures a check registered with Health.Register.
+type CheckOption = httputils.CheckOption
+
+// NewHealth creates an empty health registry.
+func NewHealth() *Health {
+	return httputils.NewHealth()
+}
+
+// WithCheckTimeout bounds a single run of the check. Defaults to 2s.
+func WithCheckTimeout(d time.Duration) CheckOption {
+	return httputils.WithCheckTimeout(d)
+}
+
+// WithCheckCacheTTL sets how long a check result is reused before the check
+// runs again. Defaults to 1s.
+func WithCheckCacheTTL(d time.Duration) CheckOption {
+	return httputils.WithCheckCacheTTL(d)
+}
+
+// WithCheckNonCritical reports failures of the check without failing the
+// probe; the overall status becomes "degraded" instead of "unhealthy".
+func WithCheckNonCritical() CheckOption {
+	return httputils.WithCheckNonCritical()
+}
+
+// WithCheckLiveness includes the check in the liveness probe as well as the
+// readiness probe.
+func WithCheckLiveness() CheckOption {
+	return httputils.WithCheckLiveness()
+}
+
+// PingCheck adapts anything with a PingContext method, such as the *sql.DB
+// returned by sqlite.NewDB, into a CheckFunc.
+func PingCheck(p interface{ PingContext(context.Context) error }) CheckFunc {
+	return httputils.PingCheck(p)
+}
+
+// DialCheck returns a CheckFunc that succeeds when a connection to address
+// can be established.
+func DialCheck(network, address string) CheckFunc {
+	return httputils.DialCheck(network, address)
+}
+
+// HTTPCheck returns a CheckFunc that succeeds when a GET request to url
+// answers with a status code below 500.
+func HTTPCheck(url string) CheckFunc {
+	return httputils.HTTPCheck(url)
+}
@@ -0,0 +1,66 @@
+package chimux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthProbes(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name           string
+		register       func(h *Health)
+		path           string
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"no checks", func(h *Health) {}, "/readyz", http.StatusOK, "healthy"},
+		{"all healthy", func(h *Health) {
+			h.Register("db", ok)
+		}, "/readyz", http.StatusOK, "healthy"},
+		{"critical failure", func(h *Health) {
+			h.Register("db", ok)
+			h.Register("redis", fail)
+		}, "/readyz", http.StatusServiceUnavailable, "unhealthy"},
+		{"non-critical failure", func(h *Health) {
+			h.Register("db", ok)
+			h.Register("smtp", fail, WithCheckNonCritical())
+		}, "/readyz", http.StatusOK, "degraded"},
+		{"liveness ignores readiness checks", func(h *Health) {
+			h.Register("redis", fail)
+		}, "/livez", http.StatusOK, "healthy"},
+		{"liveness runs liveness checks", func(h *Health) {
+			h.Register("deadlock", fail, WithCheckLiveness())
+		}, "/livez", http.StatusServiceUnavailable, "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealth()
+			tt.register(h)
+			r := NewChi(WithHealth(h))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected %d, got %d", tt.expectedCode, w.Code)
+			}
+			var resp struct {
+				Status string `json:"status"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, resp.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package chimux
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithMaxInFlight sheds load by answering 503 Service Unavailable once n
+// requests are being served concurrently. Use WithMaxInFlightQueue to let
+// excess requests wait for a slot instead. Shed requests are recorded by the
+// metrics middleware like any other response. With WithMetrics, the limiter
+// is the only source of http_requests_in_flight: the gauge counts the
+// requests holding or queued for a slot, which is the number the limit is
+// enforced against. The health and metrics endpoints are exempt.
+func WithMaxInFlight(n int) Option {
+	return func(o *options) {
+		o.maxInFlight = n
+	}
+}
+
+// WithMaxInFlightQueue lets up to size requests wait for at most timeout when
+// the WithMaxInFlight limit is reached. Requests that find the queue full, or
+// time out waiting, are shed with 503 Service Unavailable.
+func WithMaxInFlightQueue(size int, timeout time.Duration) Option {
+	return func(o *options) {
+		o.maxInFlightQueue = size
+		o.maxInFlightQueueTimeout = timeout
+	}
+}
+
+type concurrencyLimiter struct {
+	slots        chan struct{}
+	queue        chan struct{}
+	queueTimeout time.Duration
+
+	// metrics reports the requests holding or queued for a slot as
+	// http_requests_in_flight, or is nil without WithMetrics.
+	metrics *metrics
+}
+
+func newConcurrencyLimiter(o *options, m *metrics) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		slots:        make(chan struct{}, o.maxInFlight),
+		queue:        make(chan struct{}, max(o.maxInFlightQueue, 0)),
+		queueTimeout: o.maxInFlightQueueTimeout,
+		metrics:      m,
+	}
+}
+
+// track moves the in-flight gauge together with the slots and the queue.
+func (l *concurrencyLimiter) track(delta float64) {
+	if l.metrics != nil {
+		l.metrics.AddInFlight(delta)
+	}
+}
+
+// acquire takes a slot, waiting in the queue if there is room. It reports
+// false if the request should be shed.
+func (l *concurrencyLimiter) acquire(r *http.Request) bool {
+	select {
+	case l.slots <- struct{}{}:
+		l.track(1)
+		return true
+	default:
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+		l.track(1)
+		defer func() { <-l.queue }()
+	default:
+		return false
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+	l.track(-1)
+	return false
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+	l.track(-1)
+}
+
+func loadShedMiddleware(o *options, m *metrics) func(next http.Handler) http.Handler {
+	l := newConcurrencyLimiter(o, m)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.isOperationalEndpoint(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !l.acquire(r) {
+				w.Header().Set("Connection", "close")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			defer l.release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
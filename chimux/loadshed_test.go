@@ -0,0 +1,110 @@
+package chimux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMaxInFlight(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{"shed immediately", []Option{WithMaxInFlight(1)}, http.StatusServiceUnavailable},
+		{"queue times out", []Option{WithMaxInFlight(1), WithMaxInFlightQueue(1, 10*time.Millisecond)}, http.StatusServiceUnavailable},
+		{"queued until slot frees", []Option{WithMaxInFlight(1), WithMaxInFlightQueue(1, time.Second)}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			unblock := make(chan struct{})
+
+			r := NewChi(append(tt.opts, WithHealthz())...)
+			r.Get("/block", func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-unblock
+			})
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+			var wg sync.WaitGroup
+			wg.Go(func() {
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/block", nil))
+			})
+			<-started
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("healthz: status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			time.AfterFunc(50*time.Millisecond, func() { close(unblock) })
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestMaxInFlightGauge(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := NewChi(WithMetrics(), WithMetricsRegistry(reg), WithMaxInFlight(1), WithMaxInFlightQueue(1, time.Second))
+
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	r.Get("/block", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	})
+
+	inFlight := func() float64 {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range families {
+			if f.GetName() == "http_requests_in_flight" {
+				return f.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		t.Fatal("http_requests_in_flight not registered")
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/block", nil))
+		})
+	}
+	<-started
+	for inFlight() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The slot and the queue are taken, so this request is shed without
+	// ever counting as in flight.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/block", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := inFlight(); got != 2 {
+		t.Errorf("in flight = %v, want 2: one served and one queued", got)
+	}
+
+	close(unblock)
+	wg.Wait()
+	if got := inFlight(); got != 0 {
+		t.Errorf("in flight after completion = %v, want 0", got)
+	}
+}
@@ -1,47 +1,33 @@
 package chimux
 
 import (
-	"fmt"
+	"context"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/meysam81/x/httputils"
 	"github.com/meysam81/x/logging"
 )
 
-type headerLogMode int
+type headerLogMode = httputils.HeaderLogMode
 
 const (
-	headerLogDefault headerLogMode = iota
-	headerLogAll
-	headerLogNone
+	headerLogDefault = httputils.HeaderLogDefault
+	headerLogAll     = httputils.HeaderLogAll
+	headerLogNone    = httputils.HeaderLogNone
 )
 
-// defaultLogHeaders is the curated set of headers logged in the default mode.
-// Drawn from RFC 9110 (HTTP Semantics), RFC 7239 (Forwarded), W3C Trace Context,
-// and common de facto standards (X-Request-Id).
-// User-Agent is always logged as a top-level structured field.
-var defaultLogHeaders = map[string]struct{}{
-	"accept":            {},
-	"content-length":    {},
-	"content-type":      {},
-	"forwarded":         {},
-	"host":              {},
-	"origin":            {},
-	"referer":           {},
-	"traceparent":       {},
-	"x-forwarded-for":   {},
-	"x-forwarded-proto": {},
-	"x-request-id":      {},
-}
-
 type logRequest struct{ o *options }
 
 func (l *logRequest) shouldSkip(r *http.Request) bool {
-	if !l.o.enableHealthzLogging && r.URL.Path == l.o.healthzEndpoint {
+	if !l.o.enableHealthzLogging && l.o.isHealthEndpoint(r.URL.Path) {
 		return true
 	}
 
@@ -49,51 +35,89 @@ func (l *logRequest) shouldSkip(r *http.Request) bool {
 		return true
 	}
 
+	for _, prefix := range l.o.logSkipPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	if len(l.o.logSkipUserAgents) > 0 {
+		ua := strings.ToLower(r.UserAgent())
+		for _, s := range l.o.logSkipUserAgents {
+			if strings.Contains(ua, s) {
+				return true
+			}
+		}
+	}
+
+	for _, skip := range l.o.logSkipFuncs {
+		if skip(r) {
+			return true
+		}
+	}
+
 	return false
 }
 
-var sensitiveHeaders = map[string]struct{}{
-	"authorization":       {},
-	"cookie":              {},
-	"set-cookie":          {},
-	"x-api-key":           {},
-	"x-auth-token":        {},
-	"x-access-token":      {},
-	"authentication":      {},
-	"proxy-authorization": {},
+func (l *logRequest) sampler() *httputils.LogSampler {
+	return &httputils.LogSampler{
+		Rate:          l.o.logSampleRate,
+		RouteRates:    l.o.logRouteSampleRates,
+		SlowThreshold: l.o.logSlowThreshold,
+	}
 }
 
-func isSensitiveHeader(header string) bool {
-	_, ok := sensitiveHeaders[strings.ToLower(header)]
-	return ok
+func (o *options) isHealthEndpoint(path string) bool {
+	if path == o.healthzEndpoint {
+		return true
+	}
+	return o.health != nil && (path == o.livenessEndpoint || path == o.readinessEndpoint)
 }
 
-func (l *logRequest) shouldLogHeader(header string) bool {
-	switch l.o.headerLogMode {
-	case headerLogNone:
-		return false
-	case headerLogAll:
+// isOperationalEndpoint reports whether path is served for the platform, i.e.
+// a health, metrics or debug endpoint, which protective middleware must not
+// reject or time out. The debug routes apply their own rate limit, see
+// mountDebugEndpoints.
+func (o *options) isOperationalEndpoint(path string) bool {
+	if o.isHealthEndpoint(path) || (o.enableMetrics && path == o.metricsEndpoint) {
 		return true
-	default: // headerLogDefault
-		key := strings.ToLower(header)
-		if _, ok := defaultLogHeaders[key]; ok {
-			return true
-		}
-		if _, ok := l.o.extraLogHeaders[key]; ok {
-			return true
-		}
-		return false
 	}
+	return o.enableDebugEndpoints && o.adminAddr == "" &&
+		(path == o.debugPrefix || strings.HasPrefix(path, o.debugPrefix+"/"))
 }
 
-const mask = "TRUNCATED"
+// JWTPattern matches JSON Web Tokens. It is a default redaction pattern, so
+// tokens are masked wherever they appear in logged header and query values.
+var JWTPattern = httputils.JWTPattern
+
+// policy returns the header, query and redaction policy of the access log,
+// which is shared with the gin middleware.
+func (l *logRequest) policy() *httputils.LogPolicy {
+	return &httputils.LogPolicy{
+		HeaderMode:           l.o.headerLogMode,
+		Headers:              l.o.extraLogHeaders,
+		ResponseHeaders:      l.o.responseLogHeaders,
+		SensitiveHeaders:     l.o.extraSensitiveHeaders,
+		SensitiveQueryParams: l.o.extraSensitiveQueryParams,
+		RedactPatterns:       l.o.redactPatterns,
+		DisableQuery:         l.o.disableLogQuery,
+	}
+}
 
 func (l *logRequest) log() func(next http.Handler) http.Handler {
+	policy := l.policy()
+	sampler := l.sampler()
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
+			r = r.WithContext(l.requestLogger(r).WithContext(r.Context()))
+			// WithContext stores a copy, so write the access log through the
+			// stored logger to pick up fields added by handlers.
+			logger := LoggerFrom(r.Context())
+
 			next.ServeHTTP(ww, r)
 
 			if l.shouldSkip(r) {
@@ -101,52 +125,71 @@ func (l *logRequest) log() func(next http.Handler) http.Handler {
 			}
 
 			status := ww.Status()
+			duration := time.Since(start)
 
-			var event *logging.Event
-			switch {
-			case status >= 500:
-				event = l.o.logger.Error()
-			case status >= 400:
-				event = l.o.logger.Warn()
-			default:
-				event = l.o.logger.Info()
+			if !sampler.ShouldLog(chi.RouteContext(r.Context()).RoutePattern(), status, duration) {
+				return
 			}
 
-			event = event.
+			event := logger.WithLevel(httputils.AccessLogLevel(status)).
 				Int("bytes", ww.BytesWritten()).
-				Str("duration", time.Since(start).String()).
-				Str("method", r.Method).
-				Str("path", r.URL.Path).
+				Str("duration", duration.String()).
 				Int("status", status).
 				Str("remote_addr", r.RemoteAddr).
 				Str("user_agent", r.UserAgent())
 
-			// Skip header iteration when headers are disabled.
-			if l.o.headerLogMode != headerLogNone {
-				var headers []string
-				for header, values := range r.Header {
-					if !l.shouldLogHeader(header) {
-						continue
-					}
-					var valueStr string
-					if isSensitiveHeader(header) {
-						valueStr = mask
-					} else {
-						valueStr = strings.Join(values, "; ")
-					}
-					headers = append(headers, fmt.Sprintf("%s: %s", strings.ToLower(header), valueStr))
-				}
-				if len(headers) > 0 {
-					sort.Strings(headers)
-					event = event.Str("headers", strings.Join(headers, ", "))
-				}
+			if sampler.IsSlow(duration) {
+				event = event.Bool("slow", true)
 			}
 
+			event = policy.AddFields(event, r, ww.Header())
+
 			event.Send()
 		})
 	}
 }
 
+// requestLogger derives the per-request child logger carrying the fields that
+// identify the request. The access log line is written through it as well, so
+// fields added by handlers end up on that line.
+func (l *logRequest) requestLogger(r *http.Request) *logging.Logger {
+	c := l.o.logger.With().
+		Str("method", r.Method).
+		Str("path", r.URL.Path)
+
+	if id := RequestIDFrom(r.Context()); id != "" {
+		c = c.Str("request_id", id)
+	}
+
+	sc := trace.SpanContextFromContext(r.Context())
+	if !sc.IsValid() {
+		// The server span is usually started further down the chain, but it
+		// shares the trace ID propagated by the caller.
+		sc = trace.SpanContextFromContext(propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header)))
+	}
+	if sc.IsValid() {
+		c = c.Str("trace_id", sc.TraceID().String())
+	}
+
+	logger := c.Logger()
+	return &logger
+}
+
+// LoggerFrom returns the request-scoped logger attached to ctx by the logging
+// middleware. It carries the method, path, request ID and trace ID of the
+// request. Fields added to it by handlers also appear on the access log line:
+//
+//	chimux.LoggerFrom(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+//		return c.Str("user_id", userID)
+//	})
+//
+// UpdateContext is not safe for concurrent use, so only call it from the
+// handler goroutine. Without the logging middleware a disabled logger is
+// returned.
+func LoggerFrom(ctx context.Context) *logging.Logger {
+	return zerolog.Ctx(ctx)
+}
+
 func loggingMiddleware(o *options) func(next http.Handler) http.Handler {
 	l := &logRequest{o}
 	return l.log()
@@ -1,139 +1,110 @@
 package chimux
 
 import (
+	"net"
 	"net/http"
-	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/meysam81/x/httputils"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// metrics records the httputils HTTP metrics for a chi router, labeled by
+// route pattern, so that chimux and gin services expose the same metrics.
 type metrics struct {
-	// Traffic: Rate of requests
-	httpRequestsTotal prometheus.CounterVec
+	*httputils.Metrics
 
-	// Latency: Time taken to serve requests
-	httpRequestDuration prometheus.HistogramVec
+	// unmatchedRouteLabel is used as the path label for requests that did
+	// not match any registered route, e.g. 404 and 405 responses.
+	unmatchedRouteLabel string
 
-	// Errors: Rate of requests that fail
-	httpResponseStatus prometheus.CounterVec
+	// limiterInFlight hands http_requests_in_flight to the WithMaxInFlight
+	// limiter, so that the gauge and the limit share a single count.
+	limiterInFlight bool
+}
 
-	// Saturation: Resource utilization
-	httpRequestsInFlight prometheus.Gauge
-	activeConnections    prometheus.Gauge
+// metricsOptions configures where and how the HTTP metrics are registered.
+type metricsOptions struct {
+	registerer  prometheus.Registerer
+	gatherer    prometheus.Gatherer
+	namespace   string
+	constLabels prometheus.Labels
+
+	latencyBuckets []float64
+	// nativeHistogramBucketFactor enables Prometheus native histograms for
+	// request latency when greater than 1.
+	nativeHistogramBucketFactor float64
 }
 
-func newMetrics() *metrics {
+func newMetrics(o *options) *metrics {
+	mo := &o.metricsOptions
 	return &metrics{
-		httpRequestsTotal: *promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "http_requests_total",
-				Help: "Total number of HTTP requests",
-			},
-			[]string{"method", "path", "status_code"},
-		),
-
-		httpRequestDuration: *promauto.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "http_request_duration_seconds",
-				Help:    "HTTP request duration in seconds",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"method", "path", "status_code"},
-		),
-
-		httpResponseStatus: *promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "http_response_status_total",
-				Help: "Total number of HTTP responses by status code",
-			},
-			[]string{"status_code", "status_class"},
-		),
-
-		httpRequestsInFlight: promauto.NewGauge(
-			prometheus.GaugeOpts{
-				Name: "http_requests_in_flight",
-				Help: "Number of HTTP requests currently being processed",
-			},
-		),
-
-		activeConnections: promauto.NewGauge(
-			prometheus.GaugeOpts{
-				Name: "http_active_connections",
-				Help: "Number of active HTTP connections",
-			},
-		),
+		Metrics: httputils.NewMetrics(httputils.MetricsConfig{
+			Registerer:                  mo.registerer,
+			Namespace:                   mo.namespace,
+			ConstLabels:                 mo.constLabels,
+			LatencyBuckets:              mo.latencyBuckets,
+			NativeHistogramBucketFactor: mo.nativeHistogramBucketFactor,
+		}),
+		unmatchedRouteLabel: o.metricsUnmatchedRouteLabel,
+		limiterInFlight:     o.maxInFlight > 0,
 	}
 }
 
-func (m *metrics) RecordRequest(method, path string, statusCode int, duration time.Duration) {
-	statusStr := strconv.Itoa(statusCode)
-	statusClass := getStatusClass(statusCode)
-
-	// Traffic: Increment request counter
-	m.httpRequestsTotal.WithLabelValues(method, path, statusStr).Inc()
-
-	// Latency: Record request duration
-	m.httpRequestDuration.WithLabelValues(method, path, statusStr).Observe(duration.Seconds())
-
-	// Errors: Record response status
-	m.httpResponseStatus.WithLabelValues(statusStr, statusClass).Inc()
-}
-
-func (m *metrics) IncrementInFlight() {
-	m.httpRequestsInFlight.Inc()
-}
-
-func (m *metrics) DecrementInFlight() {
-	m.httpRequestsInFlight.Dec()
+// handler returns the HTTP handler exposing the metrics gathered from the
+// configured registry, or from the default registry if none was provided.
+func (mo *metricsOptions) handler() http.Handler {
+	return httputils.MetricsHandler(mo.registerer, mo.gatherer)
 }
 
-func (m *metrics) SetActiveConnections(count float64) {
-	m.activeConnections.Set(count)
+// MetricsConnState returns an http.Server ConnState callback that keeps the
+// http_active_connections gauge up to date. Pass the same metrics options
+// given to NewChi, e.g. WithMetricsRegistry and WithMetricsNamespace, so the
+// callback updates the gauge served by the router's metrics endpoint:
+//
+//	srv := &http.Server{Handler: r, ConnState: chimux.MetricsConnState(opts...)}
+func MetricsConnState(opts ...Option) func(net.Conn, http.ConnState) {
+	o := newOptions(opts...)
+	return newMetrics(o).ConnState
 }
 
-func getStatusClass(statusCode int) string {
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		return "2xx"
-	case statusCode >= 300 && statusCode < 400:
-		return "3xx"
-	case statusCode >= 400 && statusCode < 500:
-		return "4xx"
-	case statusCode >= 500:
-		return "5xx"
-	default:
-		return "1xx"
+// routePattern returns the chi route pattern that served r, such as
+// "/users/{id}", rather than the raw URL path, which keeps the path label
+// cardinality bounded. Patterns of mounted sub-routers are joined by chi, so
+// "/api/*" + "/users/{id}" yields "/api/users/{id}"; a request that reaches a
+// mounted router but matches none of its routes is labeled with the mount
+// pattern, e.g. "/api/*". It must be called after the request has been routed.
+func (m *metrics) routePattern(r *http.Request) string {
+	if pattern := chi.RouteContext(r.Context()).RoutePattern(); pattern != "" {
+		return pattern
 	}
-}
-
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+	return m.unmatchedRouteLabel
 }
 
 func (m *metrics) middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		wrapped := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     200,
-		}
+		// The wrapper keeps http.Flusher, http.Hijacker, http.Pusher and
+		// io.ReaderFrom available to the handler.
+		wrapped := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		requestSize := httputils.CountRequestBody(r)
 
 		defer func() {
-			duration := time.Since(start)
-			m.RecordRequest(r.Method, r.URL.Path, wrapped.statusCode, duration)
+			status := wrapped.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.ObserveRequest(r.Method, m.routePattern(r), status, time.Since(start), requestSize(), int64(wrapped.BytesWritten()))
 		}()
 
-		m.IncrementInFlight()
-		defer m.DecrementInFlight()
+		if !m.limiterInFlight {
+			m.AddInFlight(1)
+			defer m.AddInFlight(-1)
+		}
 
 		next.ServeHTTP(wrapped, r)
 	})
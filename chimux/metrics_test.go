@@ -0,0 +1,165 @@
+package chimux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsRoutePattern(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := NewChi(WithMetrics(), WithMetricsRegistry(prometheus.NewRegistry()), WithMetricsUnmatchedRouteLabel("unmatched"))
+	r.Get("/users/{id}", handler)
+	r.Route("/orgs/{org}", func(r chi.Router) {
+		r.Get("/repos/{repo}", handler)
+	})
+
+	sub := NewChi()
+	sub.Get("/items/{id}", handler)
+	r.Mount("/api", sub)
+
+	for _, path := range []string{"/users/42", "/users/43", "/orgs/acme/repos/x", "/api/items/7", "/does/not/exist", "/api/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	scraped := w.Body.String()
+
+	expected := []string{
+		`http_requests_total{method="GET",path="/users/{id}",status_code="200"} 2`,
+		`http_requests_total{method="GET",path="/orgs/{org}/repos/{repo}",status_code="200"} 1`,
+		`http_requests_total{method="GET",path="/api/items/{id}",status_code="200"} 1`,
+		`http_requests_total{method="GET",path="unmatched",status_code="404"} 1`,
+		// The mount itself matched, so the label stays bounded to the mount point.
+		`http_requests_total{method="GET",path="/api/*",status_code="404"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(scraped, line) {
+			t.Errorf("scraped metrics missing %s", line)
+		}
+	}
+	if strings.Contains(scraped, `path="/users/42"`) || strings.Contains(scraped, `path="/does/not/exist"`) {
+		t.Errorf("raw URL paths leaked into the path label:\n%s", scraped)
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	t.Run("default registry tolerates multiple routers", func(t *testing.T) {
+		defer func() {
+			if err := recover(); err != nil {
+				t.Fatalf("NewChi panicked: %v", err)
+			}
+		}()
+		NewChi(WithMetrics())
+		NewChi(WithMetrics())
+	})
+
+	t.Run("custom registry with namespace and const labels", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		r := NewChi(
+			WithMetrics(),
+			WithMetricsRegistry(reg),
+			WithMetricsNamespace("myapp"),
+			WithMetricsConstLabels(prometheus.Labels{"listener": "public"}),
+			WithLatencyBuckets(0.1, 1),
+		)
+		r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make(map[string]*dto.MetricFamily)
+		for _, f := range families {
+			names[f.GetName()] = f
+		}
+
+		duration, ok := names["myapp_http_request_duration_seconds"]
+		if !ok {
+			t.Fatalf("myapp_http_request_duration_seconds not registered, got %v", families)
+		}
+		m := duration.GetMetric()[0]
+		if got := len(m.GetHistogram().GetBucket()); got != 2 {
+			t.Errorf("expected 2 buckets, got %d", got)
+		}
+		var listener string
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == "listener" {
+				listener = lp.GetValue()
+			}
+		}
+		if listener != "public" {
+			t.Errorf("expected const label listener=public, got %q", listener)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		if !strings.Contains(w.Body.String(), "myapp_http_requests_total") {
+			t.Errorf("metrics endpoint does not serve the custom registry")
+		}
+	})
+
+	t.Run("two routers with separate registries", func(t *testing.T) {
+		NewChi(WithMetrics(), WithMetricsRegistry(prometheus.NewRegistry()))
+		NewChi(WithMetrics(), WithMetricsRegistry(prometheus.NewRegistry()), WithNativeHistograms(1.1))
+	})
+}
+
+func TestMetricsSizesAndConnections(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := []Option{WithMetrics(), WithMetricsRegistry(reg)}
+
+	r := NewChi(opts...)
+	r.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("response writer does not implement http.Flusher")
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(append(body, body...))
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/echo", strings.NewReader("hello")))
+
+	sum := func(name string) float64 {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range families {
+			if f.GetName() == name {
+				m := f.GetMetric()[0]
+				if h := m.GetHistogram(); h != nil {
+					return h.GetSampleSum()
+				}
+				return m.GetGauge().GetValue()
+			}
+		}
+		t.Fatalf("metric %s not found", name)
+		return 0
+	}
+
+	if got := sum("http_request_size_bytes"); got != 5 {
+		t.Errorf("request size = %v, want 5", got)
+	}
+	if got := sum("http_response_size_bytes"); got != 10 {
+		t.Errorf("response size = %v, want 10", got)
+	}
+
+	connState := MetricsConnState(opts...)
+	connState(nil, http.StateNew)
+	connState(nil, http.StateNew)
+	connState(nil, http.StateActive)
+	connState(nil, http.StateClosed)
+	if got := sum("http_active_connections"); got != 1 {
+		t.Errorf("active connections = %v, want 1", got)
+	}
+}
@@ -0,0 +1,205 @@
+package chimux
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meysam81/x/ratelimit"
+)
+
+// RateLimitKeyFunc derives the rate limit key of a request. Requests for which
+// it returns an empty key are not limited.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// KeyByIP keys requests by client IP address. Forwarding headers are only
+// honored when verified by WithTrustedProxies: behind chi's default RealIP,
+// which accepts them from any client, requests are keyed by the TCP peer so
+// that clients cannot pick their own key. Combine it with WithTrustedProxies
+// when running behind a load balancer, otherwise every client shares the
+// address of the proxy. It is the default key of RateLimit.
+func KeyByIP(r *http.Request) string {
+	addr := peerAddr(r)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// KeyByHeader keys requests by the value of the named header, e.g. an API key.
+// Requests without the header are not limited.
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// KeyBySubject keys requests by the authenticated subject returned by fn,
+// typically read from a context value set by an authentication middleware.
+// Anonymous requests, for which fn returns an empty string, are not limited.
+func KeyBySubject(fn func(ctx context.Context) string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return fn(r.Context())
+	}
+}
+
+// KeyByRoute keys requests by method and route pattern, e.g. "GET /users/{id}",
+// so that each endpoint gets its own quota. Unmatched requests share a single
+// key.
+func KeyByRoute(r *http.Request) string {
+	return r.Method + " " + routeKey(r)
+}
+
+// routeKey returns the route pattern of r. The middleware runs before chi has
+// routed the request, so the pattern is looked up on the router directly.
+func routeKey(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return r.URL.Path
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	if rctx.Routes != nil {
+		path := rctx.RoutePath
+		if path == "" {
+			path = r.URL.RawPath
+		}
+		if path == "" {
+			path = r.URL.Path
+		}
+		if pattern := rctx.Routes.Find(chi.NewRouteContext(), r.Method, path); pattern != "" {
+			return pattern
+		}
+	}
+	return "unmatched"
+}
+
+// KeyJoin combines keys, e.g. KeyJoin(KeyBySubject(fn), KeyByRoute) for a
+// per-user, per-endpoint quota. Requests are not limited if any key is empty.
+func KeyJoin(fns ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		parts := make([]string, len(fns))
+		for i, fn := range fns {
+			parts[i] = fn(r)
+		}
+		return ratelimit.JoinKeys(parts...)
+	}
+}
+
+type rateLimitOptions struct {
+	algorithm ratelimit.Algorithm
+	key       RateLimitKeyFunc
+	prefix    string
+	failOpen  bool
+}
+
+// RateLimitOption configures RateLimit and WithRateLimit.
+type RateLimitOption func(*rateLimitOptions)
+
+// WithRateLimitAlgorithm selects the rate limiting algorithm. Defaults to the
+// sliding window counter, which is accurate at a constant memory cost per key.
+func WithRateLimitAlgorithm(algorithm ratelimit.Algorithm) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithRateLimitKey sets how requests are keyed. Defaults to KeyByIP.
+func WithRateLimitKey(fn RateLimitKeyFunc) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.key = fn
+	}
+}
+
+// WithRateLimitKeyPrefix prefixes every key, so that services sharing a Redis
+// instance or several limiters in the same service do not share quotas.
+func WithRateLimitKeyPrefix(prefix string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithRateLimitFailOpen lets requests through when Redis cannot be reached.
+// By default such requests are rejected with 503 Service Unavailable.
+func WithRateLimitFailOpen() RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.failOpen = true
+	}
+}
+
+// WithRateLimit limits requests with rl before they reach the router. The
+// health and metrics endpoints are exempt, and the debug endpoints share this
+// limiter unless WithDebugRateLimit is set. See RateLimit for the behavior and
+// the available options.
+func WithRateLimit(rl *ratelimit.RateLimit, opts ...RateLimitOption) Option {
+	return func(o *options) {
+		o.rateLimit = RateLimit(rl, opts...)
+	}
+}
+
+func rateLimitMiddleware(o *options) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := o.rateLimit(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.isOperationalEndpoint(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimit returns a middleware that limits requests with rl, for use on
+// individual routes or groups:
+//
+//	r.With(chimux.RateLimit(rl, chimux.WithRateLimitKey(chimux.KeyByRoute))).Post("/login", login)
+//
+// Every limited response carries the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, the latter in seconds. Rejected requests get
+// 429 Too Many Requests with a Retry-After header. Redis errors are logged with
+// the request logger and handled according to WithRateLimitFailOpen.
+func RateLimit(rl *ratelimit.RateLimit, opts ...RateLimitOption) func(next http.Handler) http.Handler {
+	o := &rateLimitOptions{
+		algorithm: ratelimit.AlgorithmSlidingWindowCounter,
+		key:       KeyByIP,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := o.key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := rl.Allow(r.Context(), o.algorithm, o.prefix+key)
+			if err != nil {
+				LoggerFrom(r.Context()).Error().Err(err).
+					Str("algorithm", o.algorithm.String()).
+					Bool("fail_open", o.failOpen).
+					Msg("rate limit check failed")
+				if o.failOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			res.SetHeaders(w.Header())
+			if !res.Allowed {
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
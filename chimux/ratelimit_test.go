@@ -0,0 +1,158 @@
+package chimux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/meysam81/x/ratelimit"
+	"github.com/meysam81/x/ratelimit/ratelimittest"
+)
+
+func TestRateLimit(t *testing.T) {
+	rl, _ := ratelimittest.New(t, 2)
+	r := NewChi(WithHealthz(), WithRateLimit(rl, WithRateLimitAlgorithm(ratelimit.AlgorithmFixedWindow)))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	for i := range 2 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// The header values are covered by ratelimit.Result.SetHeaders.
+	if w.Header().Get("RateLimit-Limit") == "" || w.Header().Get("Retry-After") == "" {
+		t.Errorf("rate limit headers missing: %v", w.Header())
+	}
+
+	// Other clients have their own quota.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// Health checks are never limited.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("healthz: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitSpoofedForwardingHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"default RealIP", nil},
+		{"untrusted peer", []Option{WithTrustedProxies("10.0.0.0/8")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, _ := ratelimittest.New(t, 1)
+			r := NewChi(append(tt.opts, WithRateLimit(rl))...)
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+			var limited int
+			for i := range 5 {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i))
+				req.Header.Set("X-Real-IP", "198.51.100."+strconv.Itoa(i))
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code == http.StatusTooManyRequests {
+					limited++
+				}
+			}
+			if limited != 4 {
+				t.Errorf("%d of 5 requests limited, want 4", limited)
+			}
+		})
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	rl, _ := ratelimittest.New(t, 1)
+	r := NewChi(WithTrustedProxies("192.0.2.0/24"), WithRateLimit(rl))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	// httptest requests come from 192.0.2.1, so the forwarded client counts.
+	for i := range 3 {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("client %d: status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	rl, _ := ratelimittest.New(t, 1)
+	r := NewChi(WithRateLimit(rl, WithRateLimitKey(KeyJoin(KeyByHeader("X-Api-Key"), KeyByRoute))))
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/orders", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		path   string
+		apiKey string
+		want   int
+	}{
+		{"/users/1", "a", http.StatusOK},
+		{"/users/2", "a", http.StatusTooManyRequests},
+		{"/orders", "a", http.StatusOK},
+		{"/users/1", "b", http.StatusOK},
+		{"/users/1", "", http.StatusOK},
+		{"/users/1", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.apiKey != "" {
+			req.Header.Set("X-Api-Key", tt.apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("GET %s with key %q: status = %d, want %d", tt.path, tt.apiKey, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRateLimitRedisDown(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []RateLimitOption
+		want int
+	}{
+		{"fail closed", nil, http.StatusServiceUnavailable},
+		{"fail open", []RateLimitOption{WithRateLimitFailOpen()}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, mr := ratelimittest.New(t, 1)
+			mr.Close()
+
+			r := NewChi(WithRateLimit(rl, tt.opts...))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
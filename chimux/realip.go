@@ -0,0 +1,181 @@
+package chimux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// PrivateNetworks lists the loopback, private and unique local address ranges,
+// for use with WithTrustedProxies when the load balancers run inside the same
+// private network as the service.
+var PrivateNetworks = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::1/128",
+	"fc00::/7",
+}
+
+// WithTrustedProxies replaces chi's RealIP middleware, which trusts forwarding
+// headers from any client, with one that only honors Forwarded,
+// X-Forwarded-For and X-Real-IP when the immediate peer is within one of
+// cidrs. The forwarding chain is then walked from the right and the first
+// address outside the trusted ranges becomes r.RemoteAddr. Bare IP addresses
+// are accepted as single-host ranges. NewChi panics if an entry cannot be
+// parsed.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(o *options) {
+		for _, cidr := range cidrs {
+			o.trustedProxies = append(o.trustedProxies, mustParsePrefix(cidr))
+		}
+	}
+}
+
+func mustParsePrefix(s string) netip.Prefix {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			panic(fmt.Sprintf("chimux: invalid trusted proxy %q: %v", s, err))
+		}
+		return p.Masked()
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		panic(fmt.Sprintf("chimux: invalid trusted proxy %q: %v", s, err))
+	}
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
+
+type trustedRealIP struct {
+	trusted []netip.Prefix
+}
+
+func (t *trustedRealIP) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that sent r, or false if the
+// peer is not a trusted proxy or no forwarding header is present.
+func (t *trustedRealIP) clientIP(r *http.Request) (netip.Addr, bool) {
+	peer, ok := parseHop(r.RemoteAddr)
+	if !ok || !t.isTrusted(peer) {
+		return netip.Addr{}, false
+	}
+
+	chain := forwardedChain(r.Header)
+	if len(chain) == 0 {
+		return netip.Addr{}, false
+	}
+
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop, ok := parseHop(chain[i])
+		if !ok {
+			// Obfuscated or malformed hop: nothing to its left can be
+			// trusted, so stop at the last address we could verify.
+			break
+		}
+		client = hop
+		if !t.isTrusted(hop) {
+			break
+		}
+	}
+	return client, true
+}
+
+func (t *trustedRealIP) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := t.clientIP(r); ok {
+			r.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type peerAddrKey struct{}
+
+// capturePeerAddr records the address of the TCP peer before chi's RealIP
+// middleware replaces r.RemoteAddr with a forwarding header that any client
+// can set, so that KeyByIP cannot be spoofed.
+func capturePeerAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)))
+	})
+}
+
+// peerAddr returns the address of the TCP peer of r, ignoring forwarding
+// headers unless they were verified by WithTrustedProxies.
+func peerAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(peerAddrKey{}).(string); ok {
+		return addr
+	}
+	return r.RemoteAddr
+}
+
+// forwardedChain returns the client addresses recorded by proxies, leftmost
+// first. RFC 7239 Forwarded takes precedence over X-Forwarded-For, which takes
+// precedence over X-Real-IP.
+func forwardedChain(h http.Header) []string {
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		var chain []string
+		for _, v := range values {
+			for element := range strings.SplitSeq(v, ",") {
+				chain = append(chain, forwardedFor(element))
+			}
+		}
+		return chain
+	}
+
+	if values := h.Values("X-Forwarded-For"); len(values) > 0 {
+		var chain []string
+		for _, v := range values {
+			for hop := range strings.SplitSeq(v, ",") {
+				chain = append(chain, strings.TrimSpace(hop))
+			}
+		}
+		return chain
+	}
+
+	if v := h.Get("X-Real-IP"); v != "" {
+		return []string{strings.TrimSpace(v)}
+	}
+
+	return nil
+}
+
+// forwardedFor extracts the for= parameter of a single Forwarded element,
+// e.g. `for="[2001:db8::17]:4711";proto=https`. It returns an empty string if
+// the element has none.
+func forwardedFor(element string) string {
+	for pair := range strings.SplitSeq(element, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, "for") {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// parseHop parses an address with an optional port, e.g. "192.0.2.1",
+// "192.0.2.1:80", "2001:db8::1" or "[2001:db8::1]:80".
+func parseHop(s string) (netip.Addr, bool) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,57 @@
+package chimux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"untrusted peer ignores headers", "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.9:1234"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, "10.0.0.1:1234"},
+		{"x-real-ip from trusted peer", "10.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "198.51.100.7"},
+		{"rightmost untrusted hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"spoofed leftmost entry is ignored", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "127.0.0.1, 198.51.100.7"}, "198.51.100.7"},
+		{"all hops trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"forwarded header", "10.0.0.1:1234", map[string]string{"Forwarded": `for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"forwarded takes precedence", "10.0.0.1:1234", map[string]string{"Forwarded": "for=192.0.2.60", "X-Forwarded-For": "198.51.100.7"}, "192.0.2.60"},
+		{"obfuscated hop stops the walk", "10.0.0.1:1234", map[string]string{"Forwarded": "for=192.0.2.60, for=_hidden, for=10.0.0.2"}, "10.0.0.2"},
+		{"single trusted host", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			r := NewChi(WithTrustedProxies(append(PrivateNetworks, "192.0.2.1")...))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.expected {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected NewChi to panic on an invalid CIDR")
+		}
+	}()
+	NewChi(WithTrustedProxies("10.0.0.0/33"))
+}
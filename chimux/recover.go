@@ -0,0 +1,123 @@
+package chimux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/meysam81/x/logging"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PanicRenderer writes the response sent to the client after a handler
+// panicked. err wraps the recovered value and carries the stack trace.
+type PanicRenderer func(w http.ResponseWriter, r *http.Request, err error)
+
+// WithPanicRenderer sets how the 500 Internal Server Error response of a
+// recovered panic is rendered, e.g. ProblemJSONPanicRenderer. By default only
+// the status text is written.
+func WithPanicRenderer(fn PanicRenderer) Option {
+	return func(o *options) {
+		o.panicRenderer = fn
+	}
+}
+
+// PlainTextPanicRenderer writes the status text as a plain text body. It is
+// the default renderer.
+func PlainTextPanicRenderer(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ProblemJSONPanicRenderer writes an RFC 7807 application/problem+json body.
+// The panic value is not disclosed; the request ID, when present, lets the
+// client reference the logged error.
+func ProblemJSONPanicRenderer(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(http.StatusInternalServerError),
+		Status:    http.StatusInternalServerError,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFrom(r.Context()),
+	})
+}
+
+// panicError turns a recovered value into an error carrying the stack of the
+// panicking goroutine, which the logger marshals through pkgerrors.
+func panicError(rec any) error {
+	if err, ok := rec.(error); ok {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(fmt.Errorf("%v", rec))
+}
+
+// recoverer replaces chi's Recoverer, which prints to stderr, with one that
+// logs the panic and its stack through the request logger, records it on the
+// span of a tracing middleware wrapping the router and in http_panics_total, and renders the response with the
+// configured PanicRenderer. http.ErrAbortHandler is re-panicked so that
+// net/http aborts the response silently.
+func recoverer(o *options, m *metrics) func(next http.Handler) http.Handler {
+	render := o.panicRenderer
+	if render == nil {
+		render = PlainTextPanicRenderer
+	}
+
+	fallback := o.logger
+	if fallback == nil {
+		l := logging.NewLogger()
+		fallback = &l
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				err := panicError(rec)
+
+				logger := LoggerFrom(r.Context())
+				if logger.GetLevel() == zerolog.Disabled {
+					logger = fallback
+				}
+				logger.Error().Stack().Err(err).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Msg("panic recovered")
+
+				span := trace.SpanFromContext(r.Context())
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, "panic recovered")
+
+				if m != nil {
+					m.ObservePanic(r.Method, m.routePattern(r))
+				}
+
+				if r.Header.Get("Connection") != "Upgrade" {
+					render(w, r, err)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
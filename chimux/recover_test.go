@@ -0,0 +1,140 @@
+package chimux
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/pkgerrors"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRecoverer(t *testing.T) {
+	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	reg := prometheus.NewRegistry()
+
+	r := NewChi(
+		WithLoggingMiddleware(),
+		WithLogger(&logger),
+		WithRequestID(),
+		WithMetrics(),
+		WithMetricsRegistry(reg),
+		WithPanicRenderer(ProblemJSONPanicRenderer),
+	)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", got)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response body %q: %v", w.Body.String(), err)
+	}
+	if body["status"] != float64(500) || body["request_id"] != "req-1" || body["instance"] != "/users/1" {
+		t.Errorf("unexpected problem details: %v", body)
+	}
+
+	var panicLog map[string]any
+	for line := range bytes.Lines(buf.Bytes()) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if entry["message"] == "panic recovered" {
+			panicLog = entry
+		}
+	}
+	if panicLog == nil {
+		t.Fatalf("panic not logged: %s", buf.String())
+	}
+	if panicLog["level"] != "error" || panicLog["error"] != "boom" || panicLog["request_id"] != "req-1" {
+		t.Errorf("unexpected panic log: %v", panicLog)
+	}
+	if stack, ok := panicLog["stack"].([]any); !ok || len(stack) == 0 {
+		t.Errorf("panic log has no stack trace: %v", panicLog)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var panics float64
+	for _, f := range families {
+		if f.GetName() == "http_panics_total" {
+			m := f.GetMetric()[0]
+			panics = m.GetCounter().GetValue()
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "path" && l.GetValue() != "/users/{id}" {
+					t.Errorf("path label = %q, want /users/{id}", l.GetValue())
+				}
+			}
+		}
+	}
+	if panics != 1 {
+		t.Errorf("http_panics_total = %v, want 1", panics)
+	}
+}
+
+func TestRecovererAbortHandler(t *testing.T) {
+	r := NewChi()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", rec)
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestRecovererTracing(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+	startSpan := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(r.Context(), r.Method+" "+r.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	logger := zerolog.Nop()
+	r := NewChi(WithLogger(&logger), WithTracing(startSpan))
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if got := spans[0].Status(); got.Code != codes.Error || got.Description != "panic recovered" {
+		t.Errorf("span status = %+v, want the recovered panic", got)
+	}
+}
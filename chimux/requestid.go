@@ -0,0 +1,49 @@
+package chimux
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/meysam81/x/httputils"
+	"github.com/oklog/ulid/v2"
+)
+
+const defaultRequestIDHeader = "X-Request-Id"
+
+// NewUUIDv7 generates a time-ordered UUIDv7 request ID. It is the default
+// generator of WithRequestID and is shared with the gin package through
+// httputils.NewUUIDv7.
+func NewUUIDv7() string {
+	return httputils.NewUUIDv7()
+}
+
+// NewULID generates a time-ordered ULID request ID.
+func NewULID() string {
+	return ulid.Make().String()
+}
+
+// RequestIDFrom returns the request ID stored in ctx by the request ID
+// middleware, or an empty string if there is none. The ID is stored under
+// chi's middleware.RequestIDKey, so middleware.GetReqID returns it as well.
+func RequestIDFrom(ctx context.Context) string {
+	return middleware.GetReqID(ctx)
+}
+
+func requestIDMiddleware(o *options) func(next http.Handler) http.Handler {
+	header := o.requestIDHeader
+	generate := o.requestIDGenerator
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !httputils.IsValidRequestID(id) {
+				id = generate()
+			}
+
+			w.Header().Set(header, id)
+			ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
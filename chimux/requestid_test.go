@@ -0,0 +1,78 @@
+package chimux
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		header   string
+		incoming string
+		keep     bool
+	}{
+		{"generates when missing", nil, "X-Request-Id", "", false},
+		{"accepts valid incoming", nil, "X-Request-Id", "abc-123", true},
+		{"rejects invalid incoming", nil, "X-Request-Id", "bad id\nINF forged", false},
+		{"custom header", []Option{WithRequestIDHeader("X-Correlation-Id")}, "X-Correlation-Id", "corr-1", true},
+		{"custom generator", []Option{WithRequestIDGenerator(NewULID)}, "X-Request-Id", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromCtx string
+			r := NewChi(append([]Option{WithRequestID()}, tt.opts...)...)
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				fromCtx = RequestIDFrom(r.Context())
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(tt.header, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(tt.header)
+			if got == "" {
+				t.Fatalf("response header %s not set", tt.header)
+			}
+			if got != fromCtx {
+				t.Errorf("response header %q does not match context %q", got, fromCtx)
+			}
+			if tt.keep && got != tt.incoming {
+				t.Errorf("expected incoming ID %q to be kept, got %q", tt.incoming, got)
+			}
+			if !tt.keep && got == tt.incoming {
+				t.Errorf("expected a generated ID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	r := NewChi(WithRequestID(), WithLoggingMiddleware(), WithLogger(&logger))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "req-42")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid log line %q: %v", buf.String(), err)
+	}
+	if line["request_id"] != "req-42" {
+		t.Errorf("expected request_id field req-42, got %v", line["request_id"])
+	}
+}
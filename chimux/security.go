@@ -0,0 +1,214 @@
+package chimux
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/cors"
+)
+
+// securityHeadersOptions holds the values of the security response headers.
+// An empty value leaves the header unset.
+type securityHeadersOptions struct {
+	hsts                  string
+	contentSecurityPolicy string
+	contentTypeOptions    string
+	referrerPolicy        string
+	frameOptions          string
+}
+
+func defaultSecurityHeadersOptions() securityHeadersOptions {
+	return securityHeadersOptions{
+		hsts:               hstsValue(2*365*24*time.Hour, true, false),
+		contentTypeOptions: "nosniff",
+		referrerPolicy:     "strict-origin-when-cross-origin",
+		frameOptions:       "DENY",
+	}
+}
+
+func hstsValue(maxAge time.Duration, includeSubDomains, preload bool) string {
+	if maxAge <= 0 {
+		return ""
+	}
+	v := "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	if includeSubDomains {
+		v += "; includeSubDomains"
+	}
+	if preload {
+		v += "; preload"
+	}
+	return v
+}
+
+// WithSecurityHeaders sets Strict-Transport-Security, X-Content-Type-Options,
+// Referrer-Policy and X-Frame-Options on every response. No
+// Content-Security-Policy is sent unless WithContentSecurityPolicy is used.
+func WithSecurityHeaders() Option {
+	return func(o *options) {
+		o.enableSecurityHeaders = true
+	}
+}
+
+// WithHSTS configures Strict-Transport-Security and enables the security
+// headers. A zero maxAge omits the header. Defaults to two years including
+// subdomains, without preload.
+func WithHSTS(maxAge time.Duration, includeSubDomains, preload bool) Option {
+	return func(o *options) {
+		o.enableSecurityHeaders = true
+		o.securityHeaders.hsts = hstsValue(maxAge, includeSubDomains, preload)
+	}
+}
+
+// WithContentSecurityPolicy sets the Content-Security-Policy header and
+// enables the security headers, e.g. "default-src 'none'; frame-ancestors 'none'"
+// for a JSON API.
+func WithContentSecurityPolicy(policy string) Option {
+	return func(o *options) {
+		o.enableSecurityHeaders = true
+		o.securityHeaders.contentSecurityPolicy = policy
+	}
+}
+
+// WithReferrerPolicy sets the Referrer-Policy header and enables the security
+// headers. Defaults to "strict-origin-when-cross-origin".
+func WithReferrerPolicy(policy string) Option {
+	return func(o *options) {
+		o.enableSecurityHeaders = true
+		o.securityHeaders.referrerPolicy = policy
+	}
+}
+
+// WithFrameOptions sets the X-Frame-Options header and enables the security
+// headers. Defaults to "DENY"; an empty value omits the header.
+func WithFrameOptions(value string) Option {
+	return func(o *options) {
+		o.enableSecurityHeaders = true
+		o.securityHeaders.frameOptions = value
+	}
+}
+
+func securityHeadersMiddleware(o *options) func(next http.Handler) http.Handler {
+	headers := map[string]string{
+		"Strict-Transport-Security": o.securityHeaders.hsts,
+		"Content-Security-Policy":   o.securityHeaders.contentSecurityPolicy,
+		"X-Content-Type-Options":    o.securityHeaders.contentTypeOptions,
+		"Referrer-Policy":           o.securityHeaders.referrerPolicy,
+		"X-Frame-Options":           o.securityHeaders.frameOptions,
+	}
+	for k, v := range headers {
+		if v == "" {
+			delete(headers, k)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for k, v := range headers {
+				h.Set(k, v)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func defaultCORSOptions() cors.Options {
+	return cors.Options{
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", defaultRequestIDHeader},
+		MaxAge:         300,
+	}
+}
+
+// WithCORS enables CORS for the given origins, which may contain one wildcard,
+// e.g. "https://*.example.com". Preflight requests are answered directly.
+// By default HEAD, GET, POST, PUT, PATCH and DELETE are allowed with the
+// Accept, Authorization, Content-Type and X-Request-Id headers. Pass "*" to
+// allow every origin; NewChi panics if no origin is given.
+func WithCORS(allowedOrigins ...string) Option {
+	return func(o *options) {
+		o.enableCORS = true
+		o.cors.AllowedOrigins = allowedOrigins
+	}
+}
+
+func corsMiddleware(o *options) func(next http.Handler) http.Handler {
+	if len(o.cors.AllowedOrigins) == 0 {
+		// go-chi/cors would allow every origin.
+		panic(`chimux: WithCORS requires at least one origin, use "*" to allow all`)
+	}
+	return cors.Handler(o.cors)
+}
+
+// WithCORSAllowedMethods replaces the methods allowed for cross-origin requests.
+func WithCORSAllowedMethods(methods ...string) Option {
+	return func(o *options) {
+		o.cors.AllowedMethods = methods
+	}
+}
+
+// WithCORSAllowedHeaders replaces the request headers allowed for
+// cross-origin requests.
+func WithCORSAllowedHeaders(headers ...string) Option {
+	return func(o *options) {
+		o.cors.AllowedHeaders = headers
+	}
+}
+
+// WithCORSExposedHeaders sets the response headers readable by cross-origin
+// clients.
+func WithCORSExposedHeaders(headers ...string) Option {
+	return func(o *options) {
+		o.cors.ExposedHeaders = headers
+	}
+}
+
+// WithCORSAllowCredentials allows cross-origin requests to carry cookies and
+// HTTP authentication.
+func WithCORSAllowCredentials() Option {
+	return func(o *options) {
+		o.cors.AllowCredentials = true
+	}
+}
+
+// WithCORSMaxAge sets how long browsers may cache preflight responses.
+// Defaults to 5 minutes.
+func WithCORSMaxAge(d time.Duration) Option {
+	return func(o *options) {
+		o.cors.MaxAge = int(d.Seconds())
+	}
+}
+
+// WithMaxBodySize limits request bodies to n bytes. Requests declaring a
+// larger Content-Length are rejected with 413 Request Entity Too Large before
+// reaching the handler; reading past the limit of a streamed body fails with
+// an *http.MaxBytesError, which handlers should answer with 413 as well.
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+func maxBodySizeMiddleware(n int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				w.Header().Set("Connection", "close")
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package chimux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	r := NewChi(WithSecurityHeaders(), WithContentSecurityPolicy("default-src 'none'"), WithFrameOptions(""))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'none'",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "",
+	}
+	for header, want := range expected {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	r := NewChi(WithCORS("https://*.example.com"), WithCORSAllowCredentials())
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("preflight from allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("Access-Control-Allow-Credentials = %q", got)
+		}
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("Origin", "https://evil.test")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+		}
+	})
+}
+
+func TestCORSAllOrigins(t *testing.T) {
+	r := NewChi(WithCORS("*"))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://any.test")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+}
+
+func TestCORSNoOrigins(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected NewChi to panic when WithCORS gets no origin")
+		}
+	}()
+	NewChi(WithCORS())
+}
+
+func TestMaxBodySize(t *testing.T) {
+	r := NewChi(WithMaxBodySize(4))
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{"ok", http.StatusNoContent},
+		{"too large", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(tt.body)))
+		if w.Code != tt.expected {
+			t.Errorf("body %q: expected %d, got %d", tt.body, tt.expected, w.Code)
+		}
+	}
+}
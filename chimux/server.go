@@ -0,0 +1,123 @@
+package chimux
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/meysam81/x/httputils"
+)
+
+// WithAddr sets the TCP address Serve listens on. Defaults to ":8080".
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithAddr(addr))
+	}
+}
+
+// WithReadTimeout sets http.Server.ReadTimeout. Defaults to 15s.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithReadTimeout(d))
+	}
+}
+
+// WithReadHeaderTimeout sets http.Server.ReadHeaderTimeout. Defaults to 5s.
+func WithReadHeaderTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithReadHeaderTimeout(d))
+	}
+}
+
+// WithWriteTimeout sets http.Server.WriteTimeout. Defaults to 30s.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithWriteTimeout(d))
+	}
+}
+
+// WithIdleTimeout sets http.Server.IdleTimeout. Defaults to 120s.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithIdleTimeout(d))
+	}
+}
+
+// WithMaxHeaderBytes sets http.Server.MaxHeaderBytes. Defaults to 1MB.
+func WithMaxHeaderBytes(n int) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithMaxHeaderBytes(n))
+	}
+}
+
+// WithDrainDelay sets how long Serve keeps serving after failing the
+// readiness probe, giving load balancers time to deregister the instance
+// before connections are closed. Defaults to 5s.
+func WithDrainDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithDrainDelay(d))
+	}
+}
+
+// WithShutdownTimeout bounds how long Serve waits for in-flight requests to
+// complete and for shutdown hooks to return. Defaults to 15s.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithShutdownTimeout(d))
+	}
+}
+
+// WithShutdownHook registers fn to run after the HTTP server has shut down,
+// e.g. WithShutdownHook(tracer.Shutdown) to flush pending spans. Hooks run in
+// registration order.
+func WithShutdownHook(fn func(context.Context) error) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, httputils.WithShutdownHook(fn))
+	}
+}
+
+// WithServerOptions passes further options, such as httputils.WithTLSConfig,
+// to the server runner used by Serve.
+func WithServerOptions(opts ...httputils.ServerOption) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
+
+// Serve runs handler on an http.Server with production timeouts until ctx is
+// cancelled or the process receives SIGINT or SIGTERM. It then fails the
+// readiness probe of the registry given with WithHealth, waits for the drain
+// delay, gracefully shuts the server down and runs the shutdown hooks. Pass
+// the same options given to NewChi so that the logger, health registry and
+// metrics are shared:
+//
+//	opts := []chimux.Option{chimux.WithLogger(&logger), chimux.WithHealth(h), chimux.WithMetrics()}
+//	r := chimux.NewChi(opts...)
+//	err := chimux.Serve(ctx, r, append(opts, chimux.WithShutdownHook(tracer.Shutdown))...)
+//
+// With WithAdminAddr the metrics and debug endpoints are served on a second
+// listener, which is shut down together with the main one. A second signal
+// received while draining terminates the process immediately. Serve is a thin
+// wrapper around httputils.Serve.
+func Serve(ctx context.Context, handler http.Handler, opts ...Option) error {
+	o := newOptions(opts...)
+
+	var serverOpts []httputils.ServerOption
+	if o.logger != nil {
+		serverOpts = append(serverOpts, httputils.WithServerLogger(o.logger))
+	}
+	if o.enableMetrics {
+		serverOpts = append(serverOpts, httputils.WithConnState(newMetrics(o).ConnState))
+	}
+	if o.health != nil {
+		serverOpts = append(serverOpts, httputils.WithReadinessDrain(o.health.Drain))
+	}
+	if o.adminAddr != "" {
+		admin := chi.NewRouter()
+		mountAdminEndpoints(admin, o)
+		serverOpts = append(serverOpts, httputils.WithAdminListener(o.adminAddr, admin))
+	}
+
+	return httputils.Serve(ctx, handler, append(serverOpts, o.serverOptions...)...)
+}
@@ -0,0 +1,50 @@
+package chimux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+	h := NewHealth()
+	var hookCalled bool
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, NewChi(WithHealth(h)),
+			WithAddr("127.0.0.1:0"),
+			WithHealth(h),
+			WithDrainDelay(10*time.Millisecond),
+			WithShutdownHook(func(ctx context.Context) error {
+				hookCalled = true
+				return nil
+			}),
+		)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+
+	if !hookCalled {
+		t.Error("shutdown hook was not called")
+	}
+
+	w := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail after shutdown, got %d", w.Code)
+	}
+}
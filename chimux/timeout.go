@@ -0,0 +1,62 @@
+package chimux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// WithRequestTimeout cancels the request context after d. If the handler
+// returns without writing a response once the deadline has passed, the client
+// gets 504 Gateway Timeout; handlers may answer with their own status instead,
+// e.g. 503 Service Unavailable. Handlers must watch r.Context() for the
+// timeout to take effect. The health and metrics endpoints are exempt.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.requestTimeout = d
+	}
+}
+
+// WithRouteTimeout overrides the WithRequestTimeout default for the route
+// registered with pattern, e.g. "/reports/{id}", across all methods. A zero
+// duration disables the timeout for the route, e.g. for streaming endpoints.
+// Unlike a deadline set by an inline middleware, the override may be longer
+// than the default.
+func WithRouteTimeout(pattern string, d time.Duration) Option {
+	return func(o *options) {
+		if o.routeTimeouts == nil {
+			o.routeTimeouts = make(map[string]time.Duration)
+		}
+		o.routeTimeouts[pattern] = d
+	}
+}
+
+func timeoutMiddleware(o *options) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := o.requestTimeout
+			if len(o.routeTimeouts) > 0 {
+				if routeTimeout, ok := o.routeTimeouts[routeKey(r)]; ok {
+					d = routeTimeout
+				}
+			}
+			if d <= 0 || o.isOperationalEndpoint(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				http.Error(ww, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
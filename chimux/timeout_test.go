@@ -0,0 +1,50 @@
+package chimux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	r := NewChi(
+		WithHealthz(),
+		WithRequestTimeout(10*time.Millisecond),
+		WithRouteTimeout("/slow/{id}", time.Second),
+		WithRouteTimeout("/stream", 0),
+	)
+	wait := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	r.Get("/", wait)
+	r.Get("/slow/{id}", wait)
+	r.Get("/stream", wait)
+	r.Get("/custom", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/", http.StatusGatewayTimeout},
+		{"/slow/1", http.StatusOK},
+		{"/stream", http.StatusOK},
+		{"/custom", http.StatusServiceUnavailable},
+		{"/healthz", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
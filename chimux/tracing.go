@@ -0,0 +1,46 @@
+package chimux
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracing installs mw, typically tracer.HTTPMiddleware of
+// github.com/meysam81/x/tracing, inside the recovery middleware, and records
+// recovered panics on the span it starts. Middleware added with r.Use after
+// NewChi runs inside the recoverer, which then only sees the outer request
+// context and cannot reach that span.
+func WithTracing(mw func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.tracingMiddleware = mw
+	}
+}
+
+// tracePanics records a panic on the request span and re-panics so that the
+// recovery middleware, registered before it, still handles the response.
+// Re-panicking from the deferred call keeps the original frames on the stack.
+func tracePanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec != http.ErrAbortHandler {
+				err, ok := rec.(error)
+				if !ok {
+					err = fmt.Errorf("%v", rec)
+				}
+				span := trace.SpanFromContext(r.Context())
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, "panic recovered")
+			}
+			panic(rec)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
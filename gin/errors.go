@@ -0,0 +1,250 @@
+package gin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/meysam81/x/httputils"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// errorStatus maps the errors matched by match to an HTTP status code.
+type errorStatus struct {
+	match  func(error) bool
+	status int
+}
+
+// WithProblemDetails renders the last error attached with ctx.Error as an
+// RFC 7807 application/problem+json response once the handler returns, and
+// logs it through the zerolog logger. The status code comes from the error
+// registry (see WithErrorStatus and WithErrorType), then from oversized
+// bodies (see WithMaxBodySize), which map to 413, and from binding and
+// validation errors, which map to 400 Bad Request with one entry per invalid
+// field, then from the status set by the handler, and defaults to 500.
+// Recovered panics are rendered as 500 problems as well.
+//
+// Use ctx.ShouldBind and ctx.Error rather than ctx.Bind: the latter commits a
+// 400 response before the middleware can write the problem body. Decoding
+// errors other than JSON syntax and type errors, such as io.ErrUnexpectedEOF,
+// are only recognized when marked with SetType(gin.ErrorTypeBind). Error
+// messages are only disclosed for 4xx responses and errors of type
+// gin.ErrorTypePublic. Responses already written by the handler are left
+// alone.
+func WithProblemDetails() func(*options) {
+	return func(o *options) {
+		o.enableProblemDetails = true
+	}
+}
+
+// WithErrorStatus maps errors matching target, as reported by errors.Is, to
+// status. Mappings are checked in the order they were added.
+func WithErrorStatus(target error, status int) func(*options) {
+	return func(o *options) {
+		o.errorStatuses = append(o.errorStatuses, errorStatus{
+			match:  func(err error) bool { return errors.Is(err, target) },
+			status: status,
+		})
+	}
+}
+
+// WithErrorType maps errors of type T, as reported by errors.As, to status:
+//
+//	gin.WithErrorType[*NotFoundError](http.StatusNotFound)
+func WithErrorType[T error](status int) func(*options) {
+	return func(o *options) {
+		o.errorStatuses = append(o.errorStatuses, errorStatus{
+			match: func(err error) bool {
+				var target T
+				return errors.As(err, &target)
+			},
+			status: status,
+		})
+	}
+}
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError describes a struct field that failed validation.
+type fieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+func validationErrors(err error) []fieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	fields := make([]fieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		// Drop the name of the bound struct: "User.Address.City" becomes
+		// "Address.City".
+		field := fe.Namespace()
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		fields = append(fields, fieldError{Field: field, Rule: fe.Tag(), Param: fe.Param()})
+	}
+	return fields
+}
+
+func isBindingError(e *gin.Error) bool {
+	if e.IsType(gin.ErrorTypeBind) {
+		return true
+	}
+	var (
+		verrs     validator.ValidationErrors
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	return errors.As(e.Err, &verrs) || errors.As(e.Err, &syntaxErr) || errors.As(e.Err, &typeErr)
+}
+
+// errorStatusCode picks the response status for e; see WithProblemDetails.
+func (o *options) errorStatusCode(ctx *gin.Context, e *gin.Error) int {
+	for _, es := range o.errorStatuses {
+		if es.match(e.Err) {
+			return es.status
+		}
+	}
+	var mbe *http.MaxBytesError
+	if errors.As(e.Err, &mbe) {
+		return http.StatusRequestEntityTooLarge
+	}
+	if isBindingError(e) {
+		return http.StatusBadRequest
+	}
+	if status := ctx.Writer.Status(); status >= http.StatusBadRequest {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func writeProblem(ctx *gin.Context, p problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	p.Title = http.StatusText(p.Status)
+	p.Instance = ctx.Request.URL.Path
+	p.RequestID = RequestIDFrom(ctx)
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		ctx.AbortWithStatus(p.Status)
+		return
+	}
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Data(p.Status, "application/problem+json", body)
+	ctx.Abort()
+}
+
+func problemDetailsMiddleware(o *options) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		e := ctx.Errors.Last()
+		if e == nil {
+			return
+		}
+
+		status := o.errorStatusCode(ctx, e)
+		p := problem{Status: status, Errors: validationErrors(e.Err)}
+		switch {
+		case len(p.Errors) > 0:
+			p.Detail = "request validation failed"
+		case status < http.StatusInternalServerError || e.IsType(gin.ErrorTypePublic):
+			p.Detail = e.Error()
+		}
+
+		if o.logger != nil {
+			event := o.logger.WithLevel(httputils.AccessLogLevel(status)).
+				Err(e.Err).
+				Str("method", ctx.Request.Method).
+				Str("path", ctx.Request.URL.Path).
+				Str("route", ctx.FullPath()).
+				Int("status", status).
+				Str("error_type", fmt.Sprintf("%T", e.Err))
+			if id := RequestIDFrom(ctx); id != "" {
+				event = event.Str("request_id", id)
+			}
+			if len(p.Errors) > 0 {
+				fields := make([]string, len(p.Errors))
+				for i, fe := range p.Errors {
+					fields[i] = fe.Field
+				}
+				event = event.Strs("invalid_fields", fields)
+			}
+			event.Msg("request failed")
+		}
+
+		if !ctx.Writer.Written() {
+			writeProblem(ctx, p)
+		}
+	}
+}
+
+// panicError wraps a recovered value in an error carrying the stack of the
+// panicking goroutine, which the logger marshals through pkgerrors.
+func panicError(rec any) error {
+	if err, ok := rec.(error); ok {
+		return pkgerrors.WithStack(err)
+	}
+	return pkgerrors.WithStack(fmt.Errorf("%v", rec))
+}
+
+// recovery replaces gin.Recovery. With a zerolog logger the panic and its
+// stack are logged through it, otherwise they are only printed to
+// gin.DefaultErrorWriter with WithKeepDefaultErrorWriter. The panic is counted
+// in http_panics_total and the response is a problem+json body when
+// WithProblemDetails is set. http.ErrAbortHandler is re-panicked so that
+// net/http aborts the response silently.
+func recovery(o *options, m *metrics) gin.HandlerFunc {
+	var out io.Writer
+	if o.keepDefaultErrorWriter && o.logger == nil {
+		out = gin.DefaultErrorWriter
+	}
+
+	return gin.CustomRecoveryWithWriter(out, func(ctx *gin.Context, rec any) {
+		if rec == http.ErrAbortHandler {
+			panic(rec)
+		}
+
+		err := panicError(rec)
+
+		if o.logger != nil {
+			event := o.logger.Error().Stack().Err(err).
+				Str("method", ctx.Request.Method).
+				Str("path", ctx.Request.URL.Path)
+			if id := RequestIDFrom(ctx); id != "" {
+				event = event.Str("request_id", id)
+			}
+			event.Msg("panic recovered")
+		}
+
+		if m != nil {
+			m.ObservePanic(ctx.Request.Method, m.routePattern(ctx))
+		}
+
+		if o.enableProblemDetails && !ctx.Writer.Written() {
+			writeProblem(ctx, problem{Status: http.StatusInternalServerError})
+			return
+		}
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,196 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errGone = errors.New("resource gone")
+
+type notFoundError struct{ id string }
+
+func (e *notFoundError) Error() string { return "no such item " + e.id }
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+	}
+	var p problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProblemDetails(t *testing.T) {
+	logger, buf := newTestLogger()
+	g := NewGin(
+		WithZerologLogger(logger),
+		WithRequestID(),
+		WithProblemDetails(),
+		WithErrorStatus(errGone, http.StatusGone),
+		WithErrorType[*notFoundError](http.StatusNotFound),
+	)
+
+	type user struct {
+		Name string `json:"name" binding:"required"`
+		Age  int    `json:"age" binding:"gte=18"`
+	}
+	g.POST("/users", func(ctx *gin.Context) {
+		var u user
+		if err := ctx.ShouldBindJSON(&u); err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+		ctx.Status(http.StatusCreated)
+	})
+	g.GET("/gone", func(ctx *gin.Context) { _ = ctx.Error(errGone) })
+	g.GET("/items/:id", func(ctx *gin.Context) {
+		_ = ctx.Error(&notFoundError{id: ctx.Param("id")})
+	})
+	g.GET("/internal", func(ctx *gin.Context) { _ = ctx.Error(errors.New("db password rejected")) })
+	g.GET("/public", func(ctx *gin.Context) {
+		_ = ctx.Error(errors.New("maintenance window")).SetType(gin.ErrorTypePublic)
+		ctx.Status(http.StatusServiceUnavailable)
+	})
+	g.GET("/written", func(ctx *gin.Context) {
+		_ = ctx.Error(errors.New("partial"))
+		ctx.String(http.StatusOK, "already sent")
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		buf.Reset()
+		w := serve(g, httptest.NewRequest("POST", "/users", strings.NewReader(`{"age":3}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", w.Code)
+		}
+		p := decodeProblem(t, w)
+		expected := []fieldError{{Field: "Name", Rule: "required"}, {Field: "Age", Rule: "gte", Param: "18"}}
+		if len(p.Errors) != len(expected) {
+			t.Fatalf("errors = %+v, want %+v", p.Errors, expected)
+		}
+		for i := range expected {
+			if p.Errors[i] != expected[i] {
+				t.Errorf("errors[%d] = %+v, want %+v", i, p.Errors[i], expected[i])
+			}
+		}
+		if p.Type != "about:blank" || p.Title != "Bad Request" || p.Instance != "/users" || p.RequestID != w.Header().Get("X-Request-Id") {
+			t.Errorf("problem = %+v", p)
+		}
+
+		entry := logLines(t, buf)[0]
+		if entry["message"] != "request failed" || entry["level"] != "warn" || entry["status"] != float64(400) {
+			t.Errorf("log line = %v", entry)
+		}
+		if fields, _ := entry["invalid_fields"].([]any); len(fields) != 2 {
+			t.Errorf("invalid_fields = %v", entry["invalid_fields"])
+		}
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		w := serve(g, httptest.NewRequest("POST", "/users", strings.NewReader(`{"age":"old"}`)))
+		if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || p.Detail == "" {
+			t.Errorf("status = %d, problem = %+v", w.Code, p)
+		}
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		detail string
+	}{
+		{"/gone", http.StatusGone, "resource gone"},
+		{"/items/7", http.StatusNotFound, "no such item 7"},
+		{"/internal", http.StatusInternalServerError, ""},
+		{"/public", http.StatusServiceUnavailable, "maintenance window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := serve(g, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			p := decodeProblem(t, w)
+			if p.Status != tt.status || p.Title != http.StatusText(tt.status) || p.Detail != tt.detail {
+				t.Errorf("problem = %+v, want status %d and detail %q", p, tt.status, tt.detail)
+			}
+		})
+	}
+
+	t.Run("written response untouched", func(t *testing.T) {
+		w := serve(g, httptest.NewRequest("GET", "/written", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "already sent" {
+			t.Errorf("response = %d %q", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	t.Run("problem details", func(t *testing.T) {
+		logger, buf := newTestLogger()
+		g := NewGin(WithZerologLogger(logger), WithProblemDetails())
+		g.GET("/", func(ctx *gin.Context) { panic("boom") })
+
+		w := serve(g, httptest.NewRequest("GET", "/", nil))
+		if p := decodeProblem(t, w); w.Code != http.StatusInternalServerError || p.Detail != "" {
+			t.Errorf("status = %d, problem = %+v", w.Code, p)
+		}
+		if entry := logLines(t, buf)[0]; entry["message"] != "panic recovered" || entry["error"] != "boom" {
+			t.Errorf("log line = %v", entry)
+		}
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		g := NewGin()
+		g.GET("/", func(ctx *gin.Context) { panic("boom") })
+		if w := serve(g, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusInternalServerError || w.Body.Len() != 0 {
+			t.Errorf("response = %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("custom error handler keeps recovery", func(t *testing.T) {
+		var sawError bool
+		handler := gin.HandlerFunc(func(ctx *gin.Context) {
+			ctx.Next()
+			sawError = len(ctx.Errors) > 0
+		})
+		g := NewGin(WithCustomErrorHandler(&handler))
+		g.GET("/error", func(ctx *gin.Context) { _ = ctx.Error(errors.New("oops")) })
+		g.GET("/panic", func(ctx *gin.Context) { panic("boom") })
+
+		serve(g, httptest.NewRequest("GET", "/error", nil))
+		if !sawError {
+			t.Error("custom handler did not see the error")
+		}
+		if w := serve(g, httptest.NewRequest("GET", "/panic", nil)); w.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want 500", w.Code)
+		}
+	})
+
+	t.Run("error writer per engine", func(t *testing.T) {
+		errorWriter := gin.DefaultErrorWriter
+		t.Cleanup(func() { gin.DefaultErrorWriter = errorWriter })
+		var out bytes.Buffer
+		gin.DefaultErrorWriter = &out
+
+		quiet := NewGin()
+		loud := NewGin(WithKeepDefaultErrorWriter())
+		quiet.GET("/", func(ctx *gin.Context) { panic("quiet engine") })
+		loud.GET("/", func(ctx *gin.Context) { panic("loud engine") })
+
+		serve(quiet, httptest.NewRequest("GET", "/", nil))
+		serve(loud, httptest.NewRequest("GET", "/", nil))
+
+		if s := out.String(); !strings.Contains(s, "loud engine") || strings.Contains(s, "quiet engine") {
+			t.Errorf("error writer output = %q, want only the loud engine", s)
+		}
+	})
+}
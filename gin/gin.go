@@ -1,39 +1,110 @@
 // Package gin provides an opinionated gin.Engine factory with secure defaults:
-// release mode, discarded writers, nil trusted proxies, recovery middleware,
-// and optional zerolog middleware.
+// nil trusted proxies, recovery middleware, and optional zerolog, Prometheus
+// metrics and health check middleware. NewGin only configures the engine it
+// returns; process-wide gin settings are applied by InitGlobals.
 package gin
 
 import (
 	"io"
+	"regexp"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/httputils"
 	"github.com/meysam81/x/logging"
+	"github.com/meysam81/x/ratelimit"
+	"github.com/meysam81/x/tracing"
 )
 
 type Gin = gin.Engine
 
 type options struct {
-	keepDefaultWriter          bool
-	keepDefaultErrorWriter     bool
-	errorHandler               *gin.HandlerFunc
-	ginLoggerEnabled           bool
-	disableNullifyTrustedProxy bool
-	logger                     *logging.Logger
-	disableSetReleaseMode      bool
+	keepDefaultWriter      bool
+	keepDefaultErrorWriter bool
+	errorHandler           *gin.HandlerFunc
+	ginLoggerEnabled       bool
+	logger                 *logging.Logger
+	disableSetReleaseMode  bool
+
+	enableMetrics              bool
+	metricsEndpoint            string
+	metricsConfig              httputils.MetricsConfig
+	metricsUnmatchedRouteLabel string
+	enableHealthz              bool
+	healthzEndpoint            string
+	health                     *httputils.Health
+	livenessEndpoint           string
+	readinessEndpoint          string
+
+	trustedProxies     []string
+	trustedPlatform    string
+	enableCORS         bool
+	cors               cors.Config
+	enableRequestID    bool
+	requestIDHeader    string
+	requestIDGenerator func() string
+
+	logPolicy            httputils.LogPolicy
+	logSampler           httputils.LogSampler
+	enableHealthzLogging bool
+
+	tracer *tracing.Tracer
+
+	enableProblemDetails bool
+	errorStatuses        []errorStatus
+
+	rateLimiter      *ratelimit.RateLimit
+	rateLimitOptions []RateLimitOption
+	maxBodySize      int64
+
+	serverOptions []httputils.ServerOption
 }
 
+func newOptions(opts ...func(*options)) *options {
+	o := &options{
+		metricsEndpoint:            "/metrics",
+		metricsUnmatchedRouteLabel: "unmatched",
+		healthzEndpoint:            "/healthz",
+		livenessEndpoint:           "/livez",
+		readinessEndpoint:          "/readyz",
+		cors:                       defaultCORSConfig(),
+		requestIDHeader:            defaultRequestIDHeader,
+		requestIDGenerator:         NewUUIDv7,
+		logPolicy: httputils.LogPolicy{
+			RedactPatterns: []*regexp.Regexp{httputils.JWTPattern},
+			QueryKey:       "params",
+		},
+		logSampler: httputils.LogSampler{Rate: 1},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithKeepDefaultWriter sends the output of WithGinLoggerEnabled to
+// gin.DefaultWriter instead of discarding it. Passed to InitGlobals, it
+// leaves gin.DefaultWriter alone.
 func WithKeepDefaultWriter() func(*options) {
 	return func(o *options) {
 		o.keepDefaultWriter = true
 	}
 }
 
+// WithKeepDefaultErrorWriter lets the recovery middleware print panics to
+// gin.DefaultErrorWriter when no zerolog logger is set, instead of discarding
+// them. Passed to InitGlobals, it leaves gin.DefaultErrorWriter alone.
 func WithKeepDefaultErrorWriter() func(*options) {
 	return func(o *options) {
 		o.keepDefaultErrorWriter = true
 	}
 }
 
+// WithCustomErrorHandler registers h right after the built-in recovery
+// middleware, which stays in place, so h sees handler errors but panics never
+// escape the engine. See WithProblemDetails for the built-in error handling.
 func WithCustomErrorHandler(h *gin.HandlerFunc) func(*options) {
 	return func(o *options) {
 		o.errorHandler = h
@@ -52,26 +123,23 @@ func WithZerologLogger(l *logging.Logger) func(*options) {
 	}
 }
 
+// WithDisableSetModeRelease keeps InitGlobals from switching gin to release
+// mode. gin's mode is process-wide, so it has no effect on NewGin.
 func WithDisableSetModeRelease() func(*options) {
 	return func(o *options) {
 		o.disableSetReleaseMode = true
 	}
 }
 
-// NewGin creates a gin.Engine with opinionated secure defaults: release mode,
-// discarded default writers, nil trusted proxies, and recovery middleware.
-// Use functional options to override any of these defaults.
-func NewGin(opts ...func(*options)) *Gin {
-	g := gin.New()
-
-	o := &options{}
-	for _, opt := range opts {
-		opt(o)
-	}
-
-	if !o.disableNullifyTrustedProxy {
-		_ = g.SetTrustedProxies(nil)
-	}
+// InitGlobals applies the settings gin only supports process-wide: it
+// switches gin to release mode and discards gin.DefaultWriter and
+// gin.DefaultErrorWriter, which gin uses for its debug output. Call it once
+// from main before creating engines; WithDisableSetModeRelease,
+// WithKeepDefaultWriter and WithKeepDefaultErrorWriter opt out of each step.
+// Setting the GIN_MODE environment variable to "release" also silences the
+// debug output.
+func InitGlobals(opts ...func(*options)) {
+	o := newOptions(opts...)
 
 	if !o.disableSetReleaseMode {
 		gin.SetMode(gin.ReleaseMode)
@@ -84,19 +152,86 @@ func NewGin(opts ...func(*options)) *Gin {
 	if !o.keepDefaultErrorWriter {
 		gin.DefaultErrorWriter = io.Discard
 	}
+}
+
+// NewGin creates a gin.Engine with opinionated secure defaults: nil trusted
+// proxies, recovery middleware and discarded gin logger and panic output. Use
+// functional options to override any of these defaults.
+//
+// NewGin does not modify gin's package state, so engines built with different
+// options in one process behave independently. See InitGlobals for the
+// process-wide settings.
+func NewGin(opts ...func(*options)) *Gin {
+	g := gin.New()
+
+	o := newOptions(opts...)
+
+	setTrustedProxies(g, o)
+
+	if o.enableRequestID {
+		g.Use(requestIDMiddleware(o))
+	}
+
+	tracingEnabled := o.tracer != nil && o.tracer.Tracer != nil
+	if tracingEnabled {
+		g.Use(tracingMiddleware(o.tracer))
+	}
 
 	if o.logger != nil {
-		g.Use(zerologMiddleware(o.logger))
+		g.Use(zerologMiddleware(o))
 	}
 
 	if o.ginLoggerEnabled {
-		g.Use(gin.Logger())
+		out := io.Discard
+		if o.keepDefaultWriter {
+			out = gin.DefaultWriter
+		}
+		g.Use(gin.LoggerWithWriter(out))
+	}
+
+	var m *metrics
+	if o.enableMetrics {
+		m = newMetrics(o)
+		g.Use(m.middleware())
+	}
+
+	g.Use(recovery(o, m))
+
+	if o.enableProblemDetails {
+		g.Use(problemDetailsMiddleware(o))
 	}
 
 	if o.errorHandler != nil {
 		g.Use(*o.errorHandler)
-	} else {
-		g.Use(gin.Recovery())
+	}
+
+	if tracingEnabled {
+		g.Use(tracePanics())
+	}
+
+	if o.enableCORS {
+		g.Use(corsMiddleware(o))
+	}
+
+	if o.maxBodySize > 0 {
+		g.Use(maxBodySizeMiddleware(o.maxBodySize))
+	}
+
+	if o.rateLimiter != nil {
+		g.Use(rateLimitMiddleware(o))
+	}
+
+	if o.enableMetrics {
+		g.GET(o.metricsEndpoint, gin.WrapH(httputils.MetricsHandler(o.metricsConfig.Registerer, nil)))
+	}
+
+	if o.enableHealthz {
+		g.GET(o.healthzEndpoint, healthCheck)
+	}
+
+	if o.health != nil {
+		g.GET(o.livenessEndpoint, gin.WrapH(o.health.LivenessHandler()))
+		g.GET(o.readinessEndpoint, gin.WrapH(o.health.ReadinessHandler()))
 	}
 
 	return g
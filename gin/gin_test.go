@@ -0,0 +1,261 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	// Keep gin's debug route dump out of the test output.
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestLogger returns a logger writing JSON lines to the returned buffer.
+func newTestLogger() (*zerolog.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	l := zerolog.New(buf)
+	return &l, buf
+}
+
+// logLines decodes the JSON lines written to buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func serve(g *Gin, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewGinLeavesGlobalsAlone(t *testing.T) {
+	mode, writer, errorWriter := gin.Mode(), gin.DefaultWriter, gin.DefaultErrorWriter
+
+	NewGin(WithGinLoggerEnabled(), WithMetrics(), WithMetricsRegistry(prometheus.NewRegistry()))
+	NewGin(WithKeepDefaultWriter(), WithKeepDefaultErrorWriter(), WithDisableSetModeRelease())
+
+	if gin.Mode() != mode {
+		t.Errorf("gin mode changed from %q to %q", mode, gin.Mode())
+	}
+	if gin.DefaultWriter != writer {
+		t.Error("gin.DefaultWriter was replaced")
+	}
+	if gin.DefaultErrorWriter != errorWriter {
+		t.Error("gin.DefaultErrorWriter was replaced")
+	}
+}
+
+func TestInitGlobals(t *testing.T) {
+	mode, writer, errorWriter := gin.Mode(), gin.DefaultWriter, gin.DefaultErrorWriter
+	t.Cleanup(func() {
+		gin.SetMode(mode)
+		gin.DefaultWriter, gin.DefaultErrorWriter = writer, errorWriter
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		gin.SetMode(gin.DebugMode)
+		gin.DefaultWriter, gin.DefaultErrorWriter = os.Stdout, os.Stderr
+
+		InitGlobals()
+
+		if gin.Mode() != gin.ReleaseMode {
+			t.Errorf("mode = %q, want %q", gin.Mode(), gin.ReleaseMode)
+		}
+		if gin.DefaultWriter != io.Discard || gin.DefaultErrorWriter != io.Discard {
+			t.Error("default writers not discarded")
+		}
+	})
+
+	t.Run("opt out", func(t *testing.T) {
+		gin.SetMode(gin.DebugMode)
+		gin.DefaultWriter, gin.DefaultErrorWriter = os.Stdout, os.Stderr
+
+		InitGlobals(WithDisableSetModeRelease(), WithKeepDefaultWriter(), WithKeepDefaultErrorWriter())
+
+		if gin.Mode() != gin.DebugMode {
+			t.Errorf("mode = %q, want %q", gin.Mode(), gin.DebugMode)
+		}
+		if gin.DefaultWriter != os.Stdout || gin.DefaultErrorWriter != os.Stderr {
+			t.Error("default writers replaced")
+		}
+	})
+}
+
+// TestEnginesAreIndependent builds a public and an admin engine with
+// conflicting options in one process and checks that neither leaks into the
+// other.
+func TestEnginesAreIndependent(t *testing.T) {
+	publicLog, publicBuf := newTestLogger()
+	adminLog, adminBuf := newTestLogger()
+	publicReg, adminReg := prometheus.NewRegistry(), prometheus.NewRegistry()
+
+	public := NewGin(
+		WithZerologLogger(publicLog),
+		WithRequestID(),
+		WithRequestIDHeader("X-Public-Id"),
+		WithMetrics(),
+		WithMetricsRegistry(publicReg),
+		WithMetricsNamespace("public"),
+		WithHealthz(),
+		WithCORS("https://app.example.com"),
+		WithProblemDetails(),
+		WithDisableLogHeaders(),
+	)
+	admin := NewGin(
+		WithZerologLogger(adminLog),
+		WithRequestID(),
+		WithMetrics(),
+		WithMetricsRegistry(adminReg),
+		WithMetricsNamespace("admin"),
+		WithHealthz(),
+		WithHealthEndpoint("/-/healthy"),
+		WithTrustedProxies("10.0.0.0/8"),
+		WithLogAllHeaders(),
+		WithKeepDefaultErrorWriter(),
+	)
+
+	for _, g := range []*Gin{public, admin} {
+		g.GET("/ip", func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.ClientIP()) })
+		g.GET("/fail", func(ctx *gin.Context) {
+			_ = ctx.Error(io.ErrUnexpectedEOF)
+			ctx.Status(http.StatusBadGateway)
+		})
+	}
+
+	t.Run("request ID header", func(t *testing.T) {
+		if w := serve(public, httptest.NewRequest("GET", "/ip", nil)); w.Header().Get("X-Public-Id") == "" || w.Header().Get("X-Request-Id") != "" {
+			t.Errorf("public headers = %v, want X-Public-Id only", w.Header())
+		}
+		if w := serve(admin, httptest.NewRequest("GET", "/ip", nil)); w.Header().Get("X-Request-Id") == "" || w.Header().Get("X-Public-Id") != "" {
+			t.Errorf("admin headers = %v, want X-Request-Id only", w.Header())
+		}
+	})
+
+	t.Run("trusted proxies", func(t *testing.T) {
+		req := func() *http.Request {
+			r := httptest.NewRequest("GET", "/ip", nil)
+			r.RemoteAddr = "10.1.2.3:1234"
+			r.Header.Set("X-Forwarded-For", "203.0.113.7")
+			return r
+		}
+		if got := serve(public, req()).Body.String(); got != "10.1.2.3" {
+			t.Errorf("public ClientIP = %q, want the peer address", got)
+		}
+		if got := serve(admin, req()).Body.String(); got != "203.0.113.7" {
+			t.Errorf("admin ClientIP = %q, want the forwarded address", got)
+		}
+	})
+
+	t.Run("health endpoints", func(t *testing.T) {
+		if w := serve(public, httptest.NewRequest("GET", "/healthz", nil)); w.Code != http.StatusOK {
+			t.Errorf("public /healthz = %d", w.Code)
+		}
+		if w := serve(public, httptest.NewRequest("GET", "/-/healthy", nil)); w.Code != http.StatusNotFound {
+			t.Errorf("public /-/healthy = %d, want 404", w.Code)
+		}
+		if w := serve(admin, httptest.NewRequest("GET", "/-/healthy", nil)); w.Code != http.StatusOK {
+			t.Errorf("admin /-/healthy = %d", w.Code)
+		}
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		req := func() *http.Request {
+			r := httptest.NewRequest("GET", "/ip", nil)
+			r.Header.Set("Origin", "https://app.example.com")
+			return r
+		}
+		if got := serve(public, req()).Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("public Access-Control-Allow-Origin = %q", got)
+		}
+		if got := serve(admin, req()).Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("admin Access-Control-Allow-Origin = %q, want none", got)
+		}
+	})
+
+	t.Run("error rendering", func(t *testing.T) {
+		w := serve(public, httptest.NewRequest("GET", "/fail", nil))
+		if w.Code != http.StatusBadGateway || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("public /fail = %d %q, want a 502 problem", w.Code, w.Header().Get("Content-Type"))
+		}
+		w = serve(admin, httptest.NewRequest("GET", "/fail", nil))
+		if w.Code != http.StatusBadGateway || w.Body.Len() != 0 {
+			t.Errorf("admin /fail = %d %q, want an empty 502", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		w := serve(public, httptest.NewRequest("GET", "/metrics", nil))
+		if body := w.Body.String(); !strings.Contains(body, "public_http_requests_total") || strings.Contains(body, "admin_") {
+			t.Errorf("public metrics mix registries:\n%s", body)
+		}
+		w = serve(admin, httptest.NewRequest("GET", "/metrics", nil))
+		if body := w.Body.String(); !strings.Contains(body, "admin_http_requests_total") || strings.Contains(body, "public_") {
+			t.Errorf("admin metrics mix registries:\n%s", body)
+		}
+	})
+
+	t.Run("access logs", func(t *testing.T) {
+		publicBuf.Reset()
+		adminBuf.Reset()
+		for _, g := range []*Gin{public, admin} {
+			r := httptest.NewRequest("GET", "/ip", nil)
+			r.Header.Set("X-Custom", "value")
+			serve(g, r)
+		}
+
+		pub, adm := logLines(t, publicBuf), logLines(t, adminBuf)
+		if len(pub) != 1 || len(adm) != 1 {
+			t.Fatalf("got %d public and %d admin log lines, want 1 each", len(pub), len(adm))
+		}
+		if _, ok := pub[0]["headers"]; ok {
+			t.Errorf("public log has headers: %v", pub[0])
+		}
+		headers, _ := adm[0]["headers"].(map[string]any)
+		if headers["x-custom"] != "value" {
+			t.Errorf("admin log headers = %v, want x-custom", adm[0]["headers"])
+		}
+	})
+}
+
+func TestGinLoggerWriter(t *testing.T) {
+	writer := gin.DefaultWriter
+	t.Cleanup(func() { gin.DefaultWriter = writer })
+	var buf bytes.Buffer
+	gin.DefaultWriter = &buf
+
+	discarded := NewGin(WithGinLoggerEnabled())
+	kept := NewGin(WithGinLoggerEnabled(), WithKeepDefaultWriter())
+	for _, g := range []*Gin{discarded, kept} {
+		g.GET("/", func(ctx *gin.Context) {})
+	}
+
+	serve(discarded, httptest.NewRequest("GET", "/?engine=discarded", nil))
+	serve(kept, httptest.NewRequest("GET", "/?engine=kept", nil))
+
+	if out := buf.String(); !strings.Contains(out, "engine=kept") || strings.Contains(out, "engine=discarded") {
+		t.Errorf("gin logger output = %q, want only the kept engine", out)
+	}
+}
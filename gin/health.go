@@ -0,0 +1,58 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/httputils"
+)
+
+func WithHealthz() func(*options) {
+	return func(o *options) {
+		o.enableHealthz = true
+	}
+}
+
+func WithHealthEndpoint(uri string) func(*options) {
+	return func(o *options) {
+		o.healthzEndpoint = uri
+	}
+}
+
+// WithHealth serves the liveness and readiness probes of h, by default on
+// "/livez" and "/readyz". Passed to Serve as well, it fails the readiness
+// probe as soon as shutdown begins so that load balancers stop routing new
+// traffic during the drain delay.
+func WithHealth(h *httputils.Health) func(*options) {
+	return func(o *options) {
+		o.health = h
+	}
+}
+
+func WithLivenessEndpoint(uri string) func(*options) {
+	return func(o *options) {
+		o.livenessEndpoint = uri
+	}
+}
+
+func WithReadinessEndpoint(uri string) func(*options) {
+	return func(o *options) {
+		o.readinessEndpoint = uri
+	}
+}
+
+// isHealthEndpoint reports whether path is one of the enabled health probes.
+func (o *options) isHealthEndpoint(path string) bool {
+	if o.enableHealthz && path == o.healthzEndpoint {
+		return true
+	}
+	return o.health != nil && (path == o.livenessEndpoint || path == o.readinessEndpoint)
+}
+
+// healthCheck answers the liveness probe with the same body as chimux.
+func healthCheck(ctx *gin.Context) {
+	body := fmt.Sprintf(`{"status":"healthy","timestamp":"%s"}`, time.Now().UTC().Format(time.RFC3339))
+	ctx.Data(http.StatusOK, "application/json", []byte(body))
+}
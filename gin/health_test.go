@@ -0,0 +1,58 @@
+package gin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/meysam81/x/httputils"
+)
+
+func TestHealthz(t *testing.T) {
+	g := NewGin(WithHealthz())
+
+	w := serve(g, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var body struct {
+		Status    string `json:"status"`
+		Timestamp string `json:"timestamp"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != "healthy" || body.Timestamp == "" {
+		t.Errorf("body = %s", w.Body.String())
+	}
+}
+
+func TestHealthzDisabledByDefault(t *testing.T) {
+	if w := serve(NewGin(), httptest.NewRequest("GET", "/healthz", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	h := httputils.NewHealth()
+	h.Register("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+	logger, buf := newTestLogger()
+	g := NewGin(WithHealth(h), WithZerologLogger(logger))
+
+	if w := serve(g, httptest.NewRequest("GET", "/livez", nil)); w.Code != http.StatusOK {
+		t.Errorf("/livez status = %d, want 200", w.Code)
+	}
+	if w := serve(g, httptest.NewRequest("GET", "/readyz", nil)); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz status = %d, want 503", w.Code)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("probes were logged: %s", buf.String())
+	}
+}
@@ -1,37 +1,175 @@
 package gin
 
 import (
+	"regexp"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/meysam81/x/logging"
+	"github.com/meysam81/x/httputils"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func zerologMiddleware(logger *logging.Logger) gin.HandlerFunc {
+// WithLogAllHeaders configures the zerolog middleware to include every
+// request header.
+func WithLogAllHeaders() func(*options) {
+	return func(o *options) {
+		o.logPolicy.HeaderMode = httputils.HeaderLogAll
+	}
+}
+
+// WithDisableLogHeaders configures the zerolog middleware to omit request
+// headers.
+func WithDisableLogHeaders() func(*options) {
+	return func(o *options) {
+		o.logPolicy.HeaderMode = httputils.HeaderLogNone
+	}
+}
+
+// WithLogHeaders adds extra headers to the default logging set.
+// Header names are case-insensitive.
+func WithLogHeaders(headers ...string) func(*options) {
+	return func(o *options) {
+		o.logPolicy.Headers = httputils.LowerSet(o.logPolicy.Headers, headers...)
+	}
+}
+
+// WithLogResponseHeaders logs the given response headers. Sensitive headers,
+// such as Set-Cookie, are masked.
+func WithLogResponseHeaders(headers ...string) func(*options) {
+	return func(o *options) {
+		o.logPolicy.ResponseHeaders = httputils.LowerSet(o.logPolicy.ResponseHeaders, headers...)
+	}
+}
+
+// WithSensitiveHeaders adds headers whose values are masked in logs, on top
+// of the built-in set (Authorization, Cookie, X-Api-Key, ...).
+func WithSensitiveHeaders(headers ...string) func(*options) {
+	return func(o *options) {
+		o.logPolicy.SensitiveHeaders = httputils.LowerSet(o.logPolicy.SensitiveHeaders, headers...)
+	}
+}
+
+// WithSensitiveQueryParams adds query parameters whose values are masked in
+// logs, on top of the built-in set (token, password, api_key, ...).
+func WithSensitiveQueryParams(params ...string) func(*options) {
+	return func(o *options) {
+		o.logPolicy.SensitiveQueryParams = httputils.LowerSet(o.logPolicy.SensitiveQueryParams, params...)
+	}
+}
+
+// WithRedactPatterns masks every match of patterns in logged header and query
+// values, in addition to JSON Web Tokens.
+func WithRedactPatterns(patterns ...*regexp.Regexp) func(*options) {
+	return func(o *options) {
+		o.logPolicy.RedactPatterns = append(o.logPolicy.RedactPatterns, patterns...)
+	}
+}
+
+// WithDisableLogQuery omits the query string from the access log.
+func WithDisableLogQuery() func(*options) {
+	return func(o *options) {
+		o.logPolicy.DisableQuery = true
+	}
+}
+
+// WithLogSampleRate logs only the given fraction, from 0 to 1, of successful
+// requests. Errors (4xx/5xx) and slow requests are always logged.
+func WithLogSampleRate(rate float64) func(*options) {
+	return func(o *options) {
+		o.logSampler.Rate = rate
+	}
+}
+
+// WithLogRouteSampleRate overrides the sample rate for the route registered
+// with fullPath, e.g. "/users/:id".
+func WithLogRouteSampleRate(fullPath string, rate float64) func(*options) {
+	return func(o *options) {
+		if o.logSampler.RouteRates == nil {
+			o.logSampler.RouteRates = make(map[string]float64)
+		}
+		o.logSampler.RouteRates[fullPath] = rate
+	}
+}
+
+// WithLogSlowThreshold always logs requests taking at least d, marked with
+// slow=true.
+func WithLogSlowThreshold(d time.Duration) func(*options) {
+	return func(o *options) {
+		o.logSampler.SlowThreshold = d
+	}
+}
+
+// WithLogHealthRequests logs requests to the health and metrics endpoints,
+// which are skipped by default.
+func WithLogHealthRequests() func(*options) {
+	return func(o *options) {
+		o.enableHealthzLogging = true
+	}
+}
+
+// isOperationalEndpoint reports whether path is the health or metrics
+// endpoint, which are exempt from access logging and rate limiting.
+func (o *options) isOperationalEndpoint(path string) bool {
+	return o.isHealthEndpoint(path) || (o.enableMetrics && path == o.metricsEndpoint)
+}
+
+func zerologMiddleware(o *options) gin.HandlerFunc {
+	logger := o.logger
+	policy := &o.logPolicy
+	sampler := &o.logSampler
+
 	return func(ctx *gin.Context) {
 		start := time.Now()
 
 		ctx.Next()
 
-		end := time.Since(start).Abs().String()
+		if !o.enableHealthzLogging && o.isOperationalEndpoint(ctx.Request.URL.Path) {
+			return
+		}
 
 		status := ctx.Writer.Status()
+		duration := time.Since(start)
 
-		var event *logging.Event
-		if status > 400 {
-			event = logger.Error()
-		} else {
-			event = logger.Info()
+		if !sampler.ShouldLog(ctx.FullPath(), status, duration) {
+			return
 		}
 
-		event.
+		event := logger.WithLevel(httputils.AccessLogLevel(status)).
 			Str("method", ctx.Request.Method).
-			Str("path", ctx.Request.URL.Path).
+			Str("path", ctx.Request.URL.Path)
+
+		if id := RequestIDFrom(ctx); id != "" {
+			event = event.Str("request_id", id)
+		}
+
+		if sc := trace.SpanContextFromContext(ctx.Request.Context()); sc.IsValid() {
+			event = event.
+				Str("trace_id", sc.TraceID().String()).
+				Str("span_id", sc.SpanID().String())
+		} else if sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))); sc.IsValid() {
+			// Without WithTracer there is no server span, but the caller's
+			// trace ID still ties the request to its trace.
+			event = event.Str("trace_id", sc.TraceID().String())
+		}
+
+		// response-size, latency, user-agent and params keep the keys of the
+		// original gin access log, so existing log queries keep working.
+		event = event.
+			Int("response-size", max(ctx.Writer.Size(), 0)).
+			Str("latency", duration.String()).
 			Int("status", status).
-			Interface("params", ctx.Request.URL.Query()).
-			Str("user-agent", ctx.Request.UserAgent()).
-			Int("response-size", ctx.Writer.Size()).
-			Str("latency", end).
-			Send()
+			Str("remote_addr", ctx.ClientIP()).
+			Str("user-agent", ctx.Request.UserAgent())
+
+		if sampler.IsSlow(duration) {
+			event = event.Bool("slow", true)
+		}
+
+		if len(ctx.Errors) > 0 {
+			event = event.Strs("errors", ctx.Errors.Errors())
+		}
+
+		policy.AddFields(event, ctx.Request, ctx.Writer.Header()).Send()
 	}
 }
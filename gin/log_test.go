@@ -0,0 +1,229 @@
+package gin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestZerologMiddlewareLevels(t *testing.T) {
+	logger, buf := newTestLogger()
+	g := NewGin(WithZerologLogger(logger))
+	g.GET("/status/:code", func(ctx *gin.Context) {
+		switch ctx.Param("code") {
+		case "400":
+			_ = ctx.Error(errors.New("bad input"))
+			ctx.Status(http.StatusBadRequest)
+		case "503":
+			ctx.Status(http.StatusServiceUnavailable)
+		}
+	})
+
+	tests := []struct {
+		path  string
+		level string
+	}{
+		{"/status/200", "info"},
+		{"/status/400", "warn"},
+		{"/status/503", "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			buf.Reset()
+			serve(g, httptest.NewRequest("GET", tt.path, nil))
+			lines := logLines(t, buf)
+			if len(lines) != 1 {
+				t.Fatalf("got %d log lines, want 1", len(lines))
+			}
+			if lines[0]["level"] != tt.level {
+				t.Errorf("level = %v, want %s", lines[0]["level"], tt.level)
+			}
+		})
+	}
+
+	buf.Reset()
+	serve(g, httptest.NewRequest("GET", "/status/400", nil))
+	if errs, _ := logLines(t, buf)[0]["errors"].([]any); len(errs) != 1 || errs[0] != "bad input" {
+		t.Errorf("errors = %v, want [bad input]", errs)
+	}
+}
+
+func TestZerologMiddlewareMasking(t *testing.T) {
+	logger, buf := newTestLogger()
+	g := NewGin(
+		WithZerologLogger(logger),
+		WithLogHeaders("X-Tenant", "X-Internal-Secret"),
+		WithSensitiveHeaders("X-Internal-Secret"),
+		WithSensitiveQueryParams("otp"),
+		WithRedactPatterns(regexp.MustCompile(`\d{4}-\d{4}`)),
+		WithLogResponseHeaders("Set-Cookie", "X-Version"),
+	)
+	g.GET("/", func(ctx *gin.Context) {
+		ctx.Header("Set-Cookie", "session=abc")
+		ctx.Header("X-Version", "1.2.3")
+	})
+
+	r := httptest.NewRequest("GET", "/?token=abc&otp=123&card=1234-5678&page=2", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Tenant", "acme")
+	r.Header.Set("X-Internal-Secret", "s3cr3t")
+	r.Header.Set("Referer", "https://example.com/?t=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig")
+	serve(g, r)
+
+	entry := logLines(t, buf)[0]
+	headers, _ := entry["headers"].(map[string]any)
+	query, _ := entry["params"].(map[string]any)
+	response, _ := entry["response_headers"].(map[string]any)
+
+	if _, ok := headers["authorization"]; ok {
+		t.Error("authorization is not in the allowlist but was logged")
+	}
+	checks := []struct {
+		name string
+		got  any
+		want string
+	}{
+		{"x-tenant", headers["x-tenant"], "acme"},
+		{"x-internal-secret", headers["x-internal-secret"], "TRUNCATED"},
+		{"referer", headers["referer"], "https://example.com/?t=TRUNCATED"},
+		{"token", query["token"], "TRUNCATED"},
+		{"otp", query["otp"], "TRUNCATED"},
+		{"card", query["card"], "TRUNCATED"},
+		{"page", query["page"], "2"},
+		{"set-cookie", response["set-cookie"], "TRUNCATED"},
+		{"x-version", response["x-version"], "1.2.3"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestZerologMiddlewareHeaderModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     func(*options)
+		present bool
+	}{
+		{"all", WithLogAllHeaders(), true},
+		{"none", WithDisableLogHeaders(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger()
+			g := NewGin(WithZerologLogger(logger), tt.opt)
+			g.GET("/", func(ctx *gin.Context) {})
+
+			r := httptest.NewRequest("GET", "/?a=1", nil)
+			r.Header.Set("X-Custom", "value")
+			serve(g, r)
+
+			headers, ok := logLines(t, buf)[0]["headers"].(map[string]any)
+			if ok != tt.present || (ok && headers["x-custom"] != "value") {
+				t.Errorf("headers = %v, want present=%v", headers, tt.present)
+			}
+		})
+	}
+
+	t.Run("query disabled", func(t *testing.T) {
+		logger, buf := newTestLogger()
+		g := NewGin(WithZerologLogger(logger), WithDisableLogQuery())
+		g.GET("/", func(ctx *gin.Context) {})
+		serve(g, httptest.NewRequest("GET", "/?a=1", nil))
+		if q, ok := logLines(t, buf)[0]["params"]; ok {
+			t.Errorf("query = %v, want none", q)
+		}
+	})
+}
+
+func TestZerologMiddlewareSkipsOperationalEndpoints(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		opts  []func(*options)
+		lines int
+	}{
+		{"skipped by default", nil, 0},
+		{"logged on request", []func(*options){WithLogHealthRequests()}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger()
+			opts := append([]func(*options){WithZerologLogger(logger), WithHealthz(), WithMetrics(), WithMetricsRegistry(prometheus.NewRegistry())}, tt.opts...)
+			g := NewGin(opts...)
+			serve(g, httptest.NewRequest("GET", "/healthz", nil))
+			serve(g, httptest.NewRequest("GET", "/metrics", nil))
+			if got := len(logLines(t, buf)); got != tt.lines {
+				t.Errorf("got %d log lines, want %d", got, tt.lines)
+			}
+		})
+	}
+}
+
+func TestZerologMiddlewareSampling(t *testing.T) {
+	logger, buf := newTestLogger()
+	g := NewGin(
+		WithZerologLogger(logger),
+		WithLogSampleRate(0),
+		WithLogRouteSampleRate("/always/:id", 1),
+		WithLogSlowThreshold(20*time.Millisecond),
+	)
+	g.GET("/sampled", func(ctx *gin.Context) {})
+	g.GET("/always/:id", func(ctx *gin.Context) {})
+	g.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusNotFound) })
+	g.GET("/slow", func(ctx *gin.Context) { time.Sleep(25 * time.Millisecond) })
+
+	for _, path := range []string{"/sampled", "/always/1", "/fail", "/slow"} {
+		serve(g, httptest.NewRequest("GET", path, nil))
+	}
+
+	lines := logLines(t, buf)
+	var paths []any
+	for _, l := range lines {
+		paths = append(paths, l["path"])
+	}
+	if len(lines) != 3 || paths[0] != "/always/1" || paths[1] != "/fail" || paths[2] != "/slow" {
+		t.Fatalf("logged paths = %v, want [/always/1 /fail /slow]", paths)
+	}
+	if lines[2]["slow"] != true {
+		t.Errorf("slow request not marked: %v", lines[2])
+	}
+}
+
+func TestZerologMiddlewareFields(t *testing.T) {
+	logger, buf := newTestLogger()
+	g := NewGin(WithZerologLogger(logger), WithRequestID())
+	g.GET("/users/:id", func(ctx *gin.Context) { ctx.String(http.StatusOK, "hello") })
+
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := serve(g, r)
+
+	entry := logLines(t, buf)[0]
+	expected := map[string]any{
+		"method":        "GET",
+		"path":          "/users/1",
+		"status":        float64(200),
+		"response-size": float64(5),
+		"remote_addr":   "192.0.2.1",
+		"user-agent":    "test-agent",
+		"request_id":    w.Header().Get("X-Request-Id"),
+		"trace_id":      "4bf92f3577b34da6a3ce929d0e0e4736",
+	}
+	for k, want := range expected {
+		if entry[k] != want {
+			t.Errorf("%s = %v, want %v", k, entry[k], want)
+		}
+	}
+	if _, ok := entry["latency"].(string); !ok {
+		t.Errorf("latency = %v, want a duration string", entry["latency"])
+	}
+}
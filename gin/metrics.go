@@ -0,0 +1,123 @@
+package gin
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/httputils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics records the httputils HTTP metrics for a gin engine, labeled by
+// route template. The metric names and labels match
+// github.com/meysam81/x/chimux so that the same dashboards and alerts work for
+// services built on either framework.
+type metrics struct {
+	*httputils.Metrics
+
+	unmatchedRouteLabel string
+}
+
+func WithMetrics() func(*options) {
+	return func(o *options) {
+		o.enableMetrics = true
+	}
+}
+
+func WithMetricsEndpoint(uri string) func(*options) {
+	return func(o *options) {
+		o.metricsEndpoint = uri
+	}
+}
+
+// WithMetricsRegistry registers the HTTP metrics with reg instead of the
+// global default registry. If reg is also a prometheus.Gatherer, such as a
+// *prometheus.Registry, the metrics endpoint serves it.
+func WithMetricsRegistry(reg prometheus.Registerer) func(*options) {
+	return func(o *options) {
+		o.metricsConfig.Registerer = reg
+	}
+}
+
+// WithMetricsNamespace prefixes every HTTP metric name with namespace,
+// e.g. "myapp_http_requests_total".
+func WithMetricsNamespace(namespace string) func(*options) {
+	return func(o *options) {
+		o.metricsConfig.Namespace = namespace
+	}
+}
+
+// WithMetricsConstLabels attaches constant labels to every HTTP metric.
+func WithMetricsConstLabels(labels prometheus.Labels) func(*options) {
+	return func(o *options) {
+		o.metricsConfig.ConstLabels = labels
+	}
+}
+
+// WithLatencyBuckets sets the classic histogram buckets, in seconds, of the
+// request duration metric. Defaults to prometheus.DefBuckets.
+func WithLatencyBuckets(buckets ...float64) func(*options) {
+	return func(o *options) {
+		o.metricsConfig.LatencyBuckets = buckets
+	}
+}
+
+// WithNativeHistograms records the request duration as a Prometheus native
+// histogram with the given bucket growth factor, e.g. 1.1. Classic buckets are
+// only kept alongside it when WithLatencyBuckets is also set.
+func WithNativeHistograms(bucketFactor float64) func(*options) {
+	return func(o *options) {
+		o.metricsConfig.NativeHistogramBucketFactor = bucketFactor
+	}
+}
+
+// WithMetricsUnmatchedRouteLabel sets the path label recorded for requests
+// that do not match any route (404 and 405 responses). Defaults to "unmatched".
+func WithMetricsUnmatchedRouteLabel(label string) func(*options) {
+	return func(o *options) {
+		o.metricsUnmatchedRouteLabel = label
+	}
+}
+
+func newMetrics(o *options) *metrics {
+	return &metrics{
+		Metrics:             httputils.NewMetrics(o.metricsConfig),
+		unmatchedRouteLabel: o.metricsUnmatchedRouteLabel,
+	}
+}
+
+// routePattern returns the route template that served the request, such as
+// "/users/:id", rather than the raw URL path, which keeps the path label
+// cardinality bounded.
+func (m *metrics) routePattern(ctx *gin.Context) string {
+	if path := ctx.FullPath(); path != "" {
+		return path
+	}
+	return m.unmatchedRouteLabel
+}
+
+// MetricsConnState returns an http.Server ConnState callback that keeps the
+// http_active_connections gauge up to date. Pass the same metrics options
+// given to NewGin:
+//
+//	srv := &http.Server{Handler: g, ConnState: gin.MetricsConnState(opts...)}
+func MetricsConnState(opts ...func(*options)) func(net.Conn, http.ConnState) {
+	return newMetrics(newOptions(opts...)).ConnState
+}
+
+func (m *metrics) middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		requestSize := httputils.CountRequestBody(ctx.Request)
+
+		m.AddInFlight(1)
+		defer m.AddInFlight(-1)
+
+		ctx.Next()
+
+		m.ObserveRequest(ctx.Request.Method, m.routePattern(ctx), ctx.Writer.Status(), time.Since(start),
+			requestSize(), int64(max(ctx.Writer.Size(), 0)))
+	}
+}
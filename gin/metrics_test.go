@@ -0,0 +1,102 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := NewGin(
+		WithMetrics(),
+		WithMetricsRegistry(reg),
+		WithMetricsNamespace("myapp"),
+		WithMetricsUnmatchedRouteLabel("other"),
+	)
+	g.GET("/users/:id", func(ctx *gin.Context) { ctx.String(http.StatusOK, "user") })
+	g.GET("/panic", func(ctx *gin.Context) { panic("boom") })
+
+	for _, path := range []string{"/users/1", "/users/2", "/nope", "/panic"} {
+		serve(g, httptest.NewRequest("GET", path, nil))
+	}
+
+	expected := `
+# HELP myapp_http_requests_total Total number of HTTP requests
+# TYPE myapp_http_requests_total counter
+myapp_http_requests_total{method="GET",path="/panic",status_code="500"} 1
+myapp_http_requests_total{method="GET",path="/users/:id",status_code="200"} 2
+myapp_http_requests_total{method="GET",path="other",status_code="404"} 1
+# HELP myapp_http_panics_total Total number of panics recovered from HTTP handlers
+# TYPE myapp_http_panics_total counter
+myapp_http_panics_total{method="GET",path="/panic"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "myapp_http_requests_total", "myapp_http_panics_total"); err != nil {
+		t.Error(err)
+	}
+
+	w := serve(g, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "myapp_http_request_duration_seconds") {
+		t.Errorf("GET /metrics = %d, missing the custom registry's metrics", w.Code)
+	}
+}
+
+func TestMetricsDefaultRegistry(t *testing.T) {
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("NewGin panicked: %v", err)
+		}
+	}()
+	NewGin(WithMetrics())
+	NewGin(WithMetrics())
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	g := NewGin(WithMetrics(), WithMetricsRegistry(prometheus.NewRegistry()), WithMetricsEndpoint("/-/metrics"))
+
+	if w := serve(g, httptest.NewRequest("GET", "/-/metrics", nil)); w.Code != http.StatusOK {
+		t.Errorf("GET /-/metrics = %d, want 200", w.Code)
+	}
+	if w := serve(g, httptest.NewRequest("GET", "/metrics", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("GET /metrics = %d, want 404", w.Code)
+	}
+}
+
+func TestMetricsConstLabelsAndBuckets(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := NewGin(
+		WithMetrics(),
+		WithMetricsRegistry(reg),
+		WithMetricsConstLabels(prometheus.Labels{"listener": "public"}),
+		WithLatencyBuckets(0.1, 1),
+	)
+	g.GET("/", func(ctx *gin.Context) {})
+	serve(g, httptest.NewRequest("GET", "/", nil))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		m := f.GetMetric()[0]
+		if got := len(m.GetHistogram().GetBucket()); got != 2 {
+			t.Errorf("buckets = %d, want 2", got)
+		}
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == "listener" && lp.GetValue() == "public" {
+				return
+			}
+		}
+		t.Errorf("labels = %v, want listener=public", m.GetLabel())
+		return
+	}
+	t.Fatal("http_request_duration_seconds not registered")
+}
@@ -0,0 +1,109 @@
+package gin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// WithTrustedProxies makes ctx.ClientIP honor X-Forwarded-For and X-Real-IP
+// when the immediate peer is within one of cidrs, e.g. the private ranges of
+// the load balancers. Bare IP addresses are accepted as single hosts. NewGin
+// panics if an entry cannot be parsed. By default, and when called without
+// arguments, no proxy is trusted and ctx.ClientIP returns the peer address.
+func WithTrustedProxies(cidrs ...string) func(*options) {
+	return func(o *options) {
+		o.trustedProxies = append(o.trustedProxies, cidrs...)
+	}
+}
+
+// WithTrustedPlatform makes ctx.ClientIP read the client address from the
+// header set by a platform such as gin.PlatformCloudflare,
+// gin.PlatformGoogleAppEngine or gin.PlatformFlyIO, or any custom header name.
+// Only use it when the service cannot be reached without going through the
+// platform, since the header is trusted from every peer.
+func WithTrustedPlatform(platform string) func(*options) {
+	return func(o *options) {
+		o.trustedPlatform = platform
+	}
+}
+
+func setTrustedProxies(g *Gin, o *options) {
+	g.TrustedPlatform = o.trustedPlatform
+
+	// gin trusts every peer until SetTrustedProxies is called; an empty list
+	// is passed as nil, which trusts none.
+	if err := g.SetTrustedProxies(o.trustedProxies); err != nil {
+		panic(fmt.Sprintf("gin: invalid trusted proxies: %v", err))
+	}
+}
+
+func defaultCORSConfig() cors.Config {
+	return cors.Config{
+		AllowMethods:  []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders:  []string{"Accept", "Authorization", "Content-Type", defaultRequestIDHeader},
+		AllowWildcard: true,
+		MaxAge:        5 * time.Minute,
+	}
+}
+
+// WithCORS enables CORS for the given origins, which may contain wildcards,
+// e.g. "https://*.example.com". Preflight requests are answered directly.
+// By default HEAD, GET, POST, PUT, PATCH and DELETE are allowed with the
+// Accept, Authorization, Content-Type and X-Request-Id headers. Pass "*" to
+// allow every origin; NewGin panics if no origin is given.
+func WithCORS(allowedOrigins ...string) func(*options) {
+	return func(o *options) {
+		o.enableCORS = true
+		o.cors.AllowOrigins = allowedOrigins
+	}
+}
+
+// WithCORSAllowedMethods replaces the methods allowed for cross-origin requests.
+func WithCORSAllowedMethods(methods ...string) func(*options) {
+	return func(o *options) {
+		o.cors.AllowMethods = methods
+	}
+}
+
+// WithCORSAllowedHeaders replaces the request headers allowed for
+// cross-origin requests.
+func WithCORSAllowedHeaders(headers ...string) func(*options) {
+	return func(o *options) {
+		o.cors.AllowHeaders = headers
+	}
+}
+
+// WithCORSExposedHeaders sets the response headers readable by cross-origin
+// clients.
+func WithCORSExposedHeaders(headers ...string) func(*options) {
+	return func(o *options) {
+		o.cors.ExposeHeaders = headers
+	}
+}
+
+// WithCORSAllowCredentials allows cross-origin requests to carry cookies and
+// HTTP authentication.
+func WithCORSAllowCredentials() func(*options) {
+	return func(o *options) {
+		o.cors.AllowCredentials = true
+	}
+}
+
+// WithCORSMaxAge sets how long browsers may cache preflight responses.
+// Defaults to 5 minutes.
+func WithCORSMaxAge(d time.Duration) func(*options) {
+	return func(o *options) {
+		o.cors.MaxAge = d
+	}
+}
+
+func corsMiddleware(o *options) gin.HandlerFunc {
+	if len(o.cors.AllowOrigins) == 0 {
+		// Reported by gin-contrib/cors as "all origins disabled".
+		panic(`gin: WithCORS requires at least one origin, use "*" to allow all`)
+	}
+	return cors.New(o.cors)
+}
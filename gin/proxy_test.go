@@ -0,0 +1,116 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []func(*options)
+		remote   string
+		expected string
+	}{
+		{"ignored by default", nil, "10.0.0.1:1234", "10.0.0.1"},
+		{"trusted CIDR", []func(*options){WithTrustedProxies("10.0.0.0/8")}, "10.0.0.1:1234", "203.0.113.7"},
+		{"trusted bare IP", []func(*options){WithTrustedProxies("10.0.0.1")}, "10.0.0.1:1234", "203.0.113.7"},
+		{"untrusted peer", []func(*options){WithTrustedProxies("10.0.0.0/8")}, "192.0.2.1:1234", "192.0.2.1"},
+		{"no arguments", []func(*options){WithTrustedProxies()}, "10.0.0.1:1234", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGin(tt.opts...)
+			g.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.ClientIP()) })
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			r.Header.Set("X-Forwarded-For", "203.0.113.7")
+			if got := serve(g, r).Body.String(); got != tt.expected {
+				t.Errorf("ClientIP = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewGin did not panic on an invalid CIDR")
+		}
+	}()
+	NewGin(WithTrustedProxies("not-a-cidr"))
+}
+
+func TestTrustedPlatform(t *testing.T) {
+	g := NewGin(WithTrustedPlatform(gin.PlatformCloudflare))
+	g.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.ClientIP()) })
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	if got := serve(g, r).Body.String(); got != "203.0.113.9" {
+		t.Errorf("ClientIP = %q, want the platform header", got)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	g := NewGin(
+		WithCORS("https://*.example.com"),
+		WithCORSAllowCredentials(),
+		WithCORSExposedHeaders("X-Request-Id"),
+	)
+	g.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	t.Run("allowed origin", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		w := serve(g, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("Access-Control-Allow-Credentials = %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+			t.Errorf("Access-Control-Expose-Headers = %q", got)
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		r := httptest.NewRequest("OPTIONS", "/", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", "PUT")
+		w := serve(g, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "300" {
+			t.Errorf("Access-Control-Max-Age = %q, want 300", got)
+		}
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", "https://evil.test")
+		w := serve(g, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+		}
+	})
+}
+
+func TestCORSNoOrigins(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewGin did not panic when WithCORS got no origin")
+		}
+	}()
+	NewGin(WithCORS())
+}
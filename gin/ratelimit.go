@@ -0,0 +1,217 @@
+package gin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/logging"
+	"github.com/meysam81/x/ratelimit"
+)
+
+// RateLimitKeyFunc derives the rate limit key of a request. Requests for which
+// it returns an empty key are not limited.
+type RateLimitKeyFunc func(ctx *gin.Context) string
+
+// KeyByIP keys requests by ctx.ClientIP. Combine it with WithTrustedProxies
+// or WithTrustedPlatform when running behind a load balancer, otherwise every
+// client shares the address of the proxy. It is the default key of RateLimit.
+func KeyByIP(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+// KeyByHeader keys requests by the value of the named header, e.g. an API key.
+// Requests without the header are not limited.
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+// KeyByRoute keys requests by method and route, e.g. "GET /users/:id", so that
+// each endpoint gets its own quota. Unmatched requests share a single key.
+func KeyByRoute(ctx *gin.Context) string {
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	return ctx.Request.Method + " " + route
+}
+
+// KeyJoin combines keys, e.g. KeyJoin(KeyByHeader("X-Api-Key"), KeyByRoute)
+// for a per-client, per-endpoint quota. Requests are not limited if any key is
+// empty.
+func KeyJoin(fns ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(ctx *gin.Context) string {
+		parts := make([]string, len(fns))
+		for i, fn := range fns {
+			parts[i] = fn(ctx)
+		}
+		return ratelimit.JoinKeys(parts...)
+	}
+}
+
+type rateLimitOptions struct {
+	algorithm ratelimit.Algorithm
+	key       RateLimitKeyFunc
+	prefix    string
+	failOpen  bool
+	logger    *logging.Logger
+}
+
+// RateLimitOption configures RateLimit and WithRateLimit.
+type RateLimitOption func(*rateLimitOptions)
+
+// WithRateLimitAlgorithm selects the rate limiting algorithm. Defaults to the
+// sliding window counter, which is accurate at a constant memory cost per key.
+func WithRateLimitAlgorithm(algorithm ratelimit.Algorithm) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithRateLimitKey sets how requests are keyed. Defaults to KeyByIP.
+func WithRateLimitKey(fn RateLimitKeyFunc) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.key = fn
+	}
+}
+
+// WithRateLimitKeyPrefix prefixes every key, so that services sharing a Redis
+// instance or several limiters in the same service do not share quotas.
+func WithRateLimitKeyPrefix(prefix string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithRateLimitFailOpen lets requests through when Redis cannot be reached.
+// By default such requests are rejected with 503 Service Unavailable.
+func WithRateLimitFailOpen() RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.failOpen = true
+	}
+}
+
+// WithRateLimitLogger logs Redis errors through l. WithRateLimit defaults to
+// the logger given to WithZerologLogger.
+func WithRateLimitLogger(l *logging.Logger) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.logger = l
+	}
+}
+
+// WithRateLimit limits every request with rl. The health and metrics
+// endpoints are exempt. See RateLimit for the behavior and the available
+// options.
+func WithRateLimit(rl *ratelimit.RateLimit, opts ...RateLimitOption) func(*options) {
+	return func(o *options) {
+		o.rateLimiter = rl
+		o.rateLimitOptions = opts
+	}
+}
+
+func rateLimitMiddleware(o *options) gin.HandlerFunc {
+	opts := append([]RateLimitOption{WithRateLimitLogger(o.logger)}, o.rateLimitOptions...)
+	limited := RateLimit(o.rateLimiter, opts...)
+
+	return func(ctx *gin.Context) {
+		if o.isOperationalEndpoint(ctx.Request.URL.Path) {
+			return
+		}
+		limited(ctx)
+	}
+}
+
+// RateLimit returns a middleware that limits requests with rl, for use on
+// individual routes or groups:
+//
+//	r.POST("/login", gin.RateLimit(rl, gin.WithRateLimitKey(gin.KeyByRoute)), login)
+//
+// Every limited response carries the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, the latter in seconds. Rejected requests are
+// aborted with 429 Too Many Requests and a Retry-After header. Redis errors
+// are handled according to WithRateLimitFailOpen.
+func RateLimit(rl *ratelimit.RateLimit, opts ...RateLimitOption) gin.HandlerFunc {
+	o := &rateLimitOptions{
+		algorithm: ratelimit.AlgorithmSlidingWindowCounter,
+		key:       KeyByIP,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx *gin.Context) {
+		key := o.key(ctx)
+		if key == "" {
+			return
+		}
+
+		res, err := rl.Allow(ctx.Request.Context(), o.algorithm, o.prefix+key)
+		if err != nil {
+			if o.logger != nil {
+				o.logger.Error().Err(err).
+					Str("algorithm", o.algorithm.String()).
+					Bool("fail_open", o.failOpen).
+					Str("path", ctx.Request.URL.Path).
+					Msg("rate limit check failed")
+			}
+			if !o.failOpen {
+				abortWithStatusText(ctx, http.StatusServiceUnavailable)
+			}
+			return
+		}
+
+		res.SetHeaders(ctx.Writer.Header())
+		if !res.Allowed {
+			abortWithStatusText(ctx, http.StatusTooManyRequests)
+		}
+	}
+}
+
+// abortWithStatusText stops the chain with the status text as a plain text
+// body.
+func abortWithStatusText(ctx *gin.Context, status int) {
+	ctx.String(status, http.StatusText(status))
+	ctx.Abort()
+}
+
+// WithMaxBodySize limits request bodies to n bytes. Requests declaring a
+// larger Content-Length are aborted with 413 Request Entity Too Large before
+// reaching the handler. Reading past the limit of a streamed body fails with
+// an *http.MaxBytesError; pass it to ctx.Error and the response becomes a 413
+// as well, unless the handler already wrote one.
+func WithMaxBodySize(n int64) func(*options) {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+func maxBodySizeMiddleware(n int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.ContentLength > n {
+			ctx.Header("Connection", "close")
+			abortWithStatusText(ctx, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if ctx.Request.Body != nil && ctx.Request.Body != http.NoBody {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, n)
+		}
+
+		ctx.Next()
+
+		if !ctx.Writer.Written() && isBodyTooLarge(ctx.Errors) {
+			ctx.Status(http.StatusRequestEntityTooLarge)
+		}
+	}
+}
+
+func isBodyTooLarge(errs []*gin.Error) bool {
+	for _, e := range errs {
+		var mbe *http.MaxBytesError
+		if errors.As(e.Err, &mbe) {
+			return true
+		}
+	}
+	return false
+}
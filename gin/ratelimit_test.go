@@ -0,0 +1,206 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/ratelimit"
+	"github.com/meysam81/x/ratelimit/ratelimittest"
+)
+
+func TestRateLimit(t *testing.T) {
+	rl, _ := ratelimittest.New(t, 2)
+	g := NewGin(WithHealthz(), WithRateLimit(rl, WithRateLimitAlgorithm(ratelimit.AlgorithmFixedWindow)))
+	g.GET("/", func(ctx *gin.Context) {})
+
+	for i := range 2 {
+		w := serve(g, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+
+	w := serve(g, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// The header values are covered by ratelimit.Result.SetHeaders.
+	if w.Header().Get("RateLimit-Limit") == "" || w.Header().Get("Retry-After") == "" {
+		t.Errorf("rate limit headers missing: %v", w.Header())
+	}
+
+	// Other clients have their own quota.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	if w := serve(g, req); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// The health endpoint is exempt.
+	if w := serve(g, httptest.NewRequest("GET", "/healthz", nil)); w.Code != http.StatusOK {
+		t.Errorf("healthz: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      RateLimitKeyFunc
+		requests [][2]string // path, X-Api-Key
+		codes    []int
+	}{
+		{
+			name:     "by header",
+			key:      KeyByHeader("X-Api-Key"),
+			requests: [][2]string{{"/a", "k1"}, {"/a", "k1"}, {"/a", "k2"}, {"/a", ""}, {"/a", ""}},
+			codes:    []int{200, 429, 200, 200, 200},
+		},
+		{
+			name:     "by route",
+			key:      KeyByRoute,
+			requests: [][2]string{{"/users/1", ""}, {"/users/2", ""}, {"/a", ""}},
+			codes:    []int{200, 429, 200},
+		},
+		{
+			name:     "joined",
+			key:      KeyJoin(KeyByHeader("X-Api-Key"), KeyByRoute),
+			requests: [][2]string{{"/a", "k1"}, {"/users/1", "k1"}, {"/a", "k1"}, {"/a", ""}, {"/a", ""}},
+			codes:    []int{200, 200, 429, 200, 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, _ := ratelimittest.New(t, 1)
+			g := NewGin(WithRateLimit(rl, WithRateLimitKey(tt.key)))
+			g.GET("/a", func(ctx *gin.Context) {})
+			g.GET("/users/:id", func(ctx *gin.Context) {})
+
+			for i, r := range tt.requests {
+				req := httptest.NewRequest("GET", r[0], nil)
+				if r[1] != "" {
+					req.Header.Set("X-Api-Key", r[1])
+				}
+				if w := serve(g, req); w.Code != tt.codes[i] {
+					t.Errorf("request %d (%v): status = %d, want %d", i, r, w.Code, tt.codes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitKeyByIP(t *testing.T) {
+	rl, _ := ratelimittest.New(t, 1)
+	g := NewGin(WithTrustedProxies("10.0.0.0/8"), WithRateLimit(rl))
+	g.GET("/", func(ctx *gin.Context) {})
+
+	req := func(client string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", client)
+		return r
+	}
+
+	if w := serve(g, req("203.0.113.1")); w.Code != http.StatusOK {
+		t.Errorf("first client: status = %d", w.Code)
+	}
+	if w := serve(g, req("203.0.113.2")); w.Code != http.StatusOK {
+		t.Errorf("second client behind the same proxy: status = %d", w.Code)
+	}
+	if w := serve(g, req("203.0.113.1")); w.Code != http.StatusTooManyRequests {
+		t.Errorf("first client again: status = %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimitRoute(t *testing.T) {
+	rl, _ := ratelimittest.New(t, 1)
+	g := NewGin()
+	g.POST("/login", RateLimit(rl, WithRateLimitKeyPrefix("login:")), func(ctx *gin.Context) {})
+	g.GET("/", func(ctx *gin.Context) {})
+
+	serve(g, httptest.NewRequest("POST", "/login", nil))
+	if w := serve(g, httptest.NewRequest("POST", "/login", nil)); w.Code != http.StatusTooManyRequests {
+		t.Errorf("login: status = %d, want 429", w.Code)
+	}
+	if w := serve(g, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("unlimited route: status = %d, headers = %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitRedisDown(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []RateLimitOption
+		code int
+	}{
+		{"fail closed", nil, http.StatusServiceUnavailable},
+		{"fail open", []RateLimitOption{WithRateLimitFailOpen()}, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, mr := ratelimittest.New(t, 1)
+			mr.Close()
+
+			logger, buf := newTestLogger()
+			g := NewGin(WithZerologLogger(logger), WithRateLimit(rl, tt.opts...))
+			g.GET("/", func(ctx *gin.Context) {})
+
+			if w := serve(g, httptest.NewRequest("GET", "/", nil)); w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+			if !strings.Contains(buf.String(), "rate limit check failed") {
+				t.Errorf("Redis error not logged: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	g := NewGin(WithMaxBodySize(8))
+	g.POST("/", func(ctx *gin.Context) {
+		var body map[string]any
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		if w := serve(g, httptest.NewRequest("POST", "/", strings.NewReader(`{}`))); w.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204", w.Code)
+		}
+	})
+
+	t.Run("declared length", func(t *testing.T) {
+		w := serve(g, httptest.NewRequest("POST", "/", strings.NewReader(`{"a":"too long"}`)))
+		if w.Code != http.StatusRequestEntityTooLarge || w.Header().Get("Connection") != "close" {
+			t.Errorf("status = %d, Connection = %q, want 413 and close", w.Code, w.Header().Get("Connection"))
+		}
+	})
+
+	t.Run("streamed", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"a":"too long"}`))
+		r.ContentLength = -1
+		if w := serve(g, r); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413", w.Code)
+		}
+	})
+
+	t.Run("streamed with problem details", func(t *testing.T) {
+		g := NewGin(WithMaxBodySize(8), WithProblemDetails())
+		g.POST("/", func(ctx *gin.Context) {
+			var body map[string]any
+			_ = ctx.Error(ctx.ShouldBindJSON(&body))
+		})
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"a":"too long"}`))
+		r.ContentLength = -1
+		w := serve(g, r)
+		if p := decodeProblem(t, w); w.Code != http.StatusRequestEntityTooLarge || p.Status != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, problem = %+v", w.Code, p)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/httputils"
+)
+
+const defaultRequestIDHeader = "X-Request-Id"
+
+// RequestIDKey is the gin context key under which the request ID middleware
+// stores the request ID.
+const RequestIDKey = "request_id"
+
+// WithRequestID reuses the request ID sent by the client, if it is valid, or
+// generates a new one. The ID is echoed in the response header, stored in the
+// gin context under RequestIDKey and logged by the zerolog middleware.
+func WithRequestID() func(*options) {
+	return func(o *options) {
+		o.enableRequestID = true
+	}
+}
+
+// WithRequestIDHeader sets the header carrying the request ID. Defaults to
+// "X-Request-Id".
+func WithRequestIDHeader(header string) func(*options) {
+	return func(o *options) {
+		o.requestIDHeader = header
+	}
+}
+
+// WithRequestIDGenerator sets the function generating request IDs. Defaults
+// to NewUUIDv7.
+func WithRequestIDGenerator(fn func() string) func(*options) {
+	return func(o *options) {
+		o.requestIDGenerator = fn
+	}
+}
+
+// NewUUIDv7 generates a time-ordered UUIDv7 request ID, like
+// httputils.NewUUIDv7.
+func NewUUIDv7() string {
+	return httputils.NewUUIDv7()
+}
+
+// RequestIDFrom returns the request ID of ctx, or an empty string if the
+// request ID middleware is not enabled.
+func RequestIDFrom(ctx *gin.Context) string {
+	return ctx.GetString(RequestIDKey)
+}
+
+func requestIDMiddleware(o *options) gin.HandlerFunc {
+	header := o.requestIDHeader
+	generate := o.requestIDGenerator
+
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(header)
+		if !httputils.IsValidRequestID(id) {
+			id = generate()
+		}
+
+		ctx.Header(header, id)
+		ctx.Set(RequestIDKey, id)
+		ctx.Next()
+	}
+}
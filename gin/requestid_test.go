@@ -0,0 +1,54 @@
+package gin
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/httputils"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	g := NewGin(WithRequestID(), WithRequestIDGenerator(func() string { return "generated" }))
+	g.GET("/", func(ctx *gin.Context) { got = RequestIDFrom(ctx) })
+
+	tests := []struct {
+		name     string
+		incoming string
+		expected string
+	}{
+		{"generated when missing", "", "generated"},
+		{"reused when valid", "abc-123_x.y:z", "abc-123_x.y:z"},
+		{"replaced when invalid", "bad id\n", "generated"},
+		{"replaced when too long", strings.Repeat("a", httputils.MaxRequestIDLength+1), "generated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set("X-Request-Id", tt.incoming)
+			}
+			w := serve(g, r)
+			if got != tt.expected {
+				t.Errorf("RequestIDFrom = %q, want %q", got, tt.expected)
+			}
+			if h := w.Header().Get("X-Request-Id"); h != tt.expected {
+				t.Errorf("response header = %q, want %q", h, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequestIDDisabled(t *testing.T) {
+	got := "unset"
+	g := NewGin()
+	g.GET("/", func(ctx *gin.Context) { got = RequestIDFrom(ctx) })
+
+	w := serve(g, httptest.NewRequest("GET", "/", nil))
+	if got != "" || w.Header().Get("X-Request-Id") != "" {
+		t.Errorf("RequestIDFrom = %q, header = %q, want both empty", got, w.Header().Get("X-Request-Id"))
+	}
+}
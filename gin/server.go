@@ -0,0 +1,45 @@
+package gin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/meysam81/x/httputils"
+)
+
+// WithServerOptions configures the server runner used by Serve, e.g. with
+// httputils.WithAddr, httputils.WithTLSCertFile or
+// httputils.WithAdminListener.
+func WithServerOptions(opts ...httputils.ServerOption) func(*options) {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
+
+// Serve runs handler with httputils.Serve: production timeouts, graceful
+// shutdown on SIGINT, SIGTERM or cancellation of ctx, and optional TLS and
+// admin listener. On shutdown it fails the readiness probe of the registry
+// given with WithHealth and waits for the drain delay before closing
+// connections. Pass the same options given to NewGin so that the zerolog
+// logger, health registry and connection gauge of WithMetrics are shared:
+//
+//	withLogger, withHealth, withMetrics := gin.WithZerologLogger(&logger), gin.WithHealth(h), gin.WithMetrics()
+//	g := gin.NewGin(withLogger, withHealth, withMetrics)
+//	err := gin.Serve(ctx, g, withLogger, withHealth, withMetrics,
+//		gin.WithServerOptions(httputils.WithAddr(":8080"), httputils.WithShutdownHook(tracer.Shutdown)))
+func Serve(ctx context.Context, handler http.Handler, opts ...func(*options)) error {
+	o := newOptions(opts...)
+
+	var serverOpts []httputils.ServerOption
+	if o.logger != nil {
+		serverOpts = append(serverOpts, httputils.WithServerLogger(o.logger))
+	}
+	if o.enableMetrics {
+		serverOpts = append(serverOpts, httputils.WithConnState(newMetrics(o).ConnState))
+	}
+	if o.health != nil {
+		serverOpts = append(serverOpts, httputils.WithReadinessDrain(o.health.Drain))
+	}
+
+	return httputils.Serve(ctx, handler, append(serverOpts, o.serverOptions...)...)
+}
@@ -0,0 +1,98 @@
+package gin
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/httputils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	logger, _ := newTestLogger()
+	reg := prometheus.NewRegistry()
+	h := httputils.NewHealth()
+	opts := []func(*options){WithZerologLogger(logger), WithHealth(h), WithMetrics(), WithMetricsRegistry(reg)}
+	g := NewGin(opts...)
+	g.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+
+	var hookCalled bool
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, g, append(opts, WithServerOptions(
+			httputils.WithAddr(addr),
+			httputils.WithDrainDelay(0),
+			httputils.WithShutdownHook(func(ctx context.Context) error {
+				hookCalled = true
+				return nil
+			}),
+		))...)
+	}()
+
+	var body []byte
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp, err := http.Get("http://" + addr)
+		if err == nil {
+			body, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want ok", body)
+	}
+
+	// The idle keep-alive connection of the client is still open.
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == "http_active_connections" {
+			if n := f.GetMetric()[0].GetGauge().GetValue(); n < 1 {
+				t.Errorf("http_active_connections = %v, want at least 1", n)
+			}
+		}
+	}
+	if n := testutil.CollectAndCount(reg, "http_requests_total"); n != 1 {
+		t.Errorf("http_requests_total series = %d, want 1", n)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+	if !hookCalled {
+		t.Error("shutdown hook was not called")
+	}
+
+	w := serve(g, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail after shutdown, got %d", w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracer starts a server span for every request, named after the matched
+// route, e.g. "GET /users/:id". The W3C trace context is extracted from the
+// request headers and injected into the response headers. Errors added with
+// ctx.Error and recovered panics are recorded on the span, and the zerolog
+// access log gains trace_id and span_id fields. A disabled tracer, one
+// created without a provider, adds no overhead.
+func WithTracer(t *tracing.Tracer) func(*options) {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+func tracingMiddleware(t *tracing.Tracer) gin.HandlerFunc {
+	serviceName := ""
+	if t.Config != nil {
+		serviceName = t.Config.ServiceName
+	}
+
+	return func(ctx *gin.Context) {
+		r := ctx.Request
+		propagator := otel.GetTextMapPropagator()
+
+		spanName := r.Method
+		route := ctx.FullPath()
+		if route != "" {
+			spanName += " " + route
+		}
+
+		c := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		c, span := t.Tracer.Start(c, spanName, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		span.SetAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(r.URL.Path),
+			semconv.URLQuery(r.URL.RawQuery),
+			semconv.URLScheme(r.URL.Scheme),
+			semconv.ServerAddress(r.Host),
+			semconv.ClientAddress(ctx.ClientIP()),
+			semconv.UserAgentOriginal(r.UserAgent()),
+			semconv.NetworkProtocolName("http"),
+			semconv.NetworkProtocolVersion(r.Proto),
+		)
+
+		propagator.Inject(c, propagation.HeaderCarrier(ctx.Writer.Header()))
+		ctx.Request = r.WithContext(c)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		for _, e := range ctx.Errors {
+			span.RecordError(e.Err)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// tracePanics records a panic on the request span and re-panics so that the
+// recovery middleware, registered before it, still handles the response.
+// Re-panicking from the deferred call keeps the original frames on the stack.
+func tracePanics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec != http.ErrAbortHandler {
+				err, ok := rec.(error)
+				if !ok {
+					err = fmt.Errorf("%v", rec)
+				}
+				span := trace.SpanFromContext(ctx.Request.Context())
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, "panic recovered")
+			}
+			panic(rec)
+		}()
+
+		ctx.Next()
+	}
+}
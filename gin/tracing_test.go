@@ -0,0 +1,122 @@
+package gin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meysam81/x/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newTestTracer(t *testing.T) (*tracing.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	return &tracing.Tracer{
+		Provider: tp,
+		Tracer:   tp.Tracer("test"),
+		Config:   &tracing.TracingConfig{ServiceName: "test"},
+	}, sr
+}
+
+func TestTracer(t *testing.T) {
+	tracer, sr := newTestTracer(t)
+	logger, buf := newTestLogger()
+	g := NewGin(WithTracer(tracer), WithZerologLogger(logger))
+
+	var handlerSpan trace.SpanContext
+	g.GET("/users/:id", func(ctx *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(ctx.Request.Context())
+	})
+	g.GET("/fail", func(ctx *gin.Context) {
+		_ = ctx.Error(errors.New("upstream down"))
+		ctx.Status(http.StatusBadGateway)
+	})
+	g.GET("/panic", func(ctx *gin.Context) { panic("boom") })
+
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	r.Header.Set("traceparent", testTraceparent)
+	w := serve(g, r)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /users/:id" {
+		t.Errorf("span name = %q", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span kind = %v", span.SpanKind())
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span = %s, want the incoming traceparent", got)
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Error("handler context does not carry the server span")
+	}
+	if got, want := w.Header().Get("traceparent"), "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01"; got != want {
+		t.Errorf("response traceparent = %q, want %q", got, want)
+	}
+
+	entry := logLines(t, buf)[0]
+	if entry["trace_id"] != span.SpanContext().TraceID().String() || entry["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("log trace_id/span_id = %v/%v", entry["trace_id"], entry["span_id"])
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		serve(g, httptest.NewRequest("GET", "/fail", nil))
+		span := sr.Ended()[1]
+		if span.Status().Code != codes.Error {
+			t.Errorf("status = %v, want Error", span.Status())
+		}
+		if events := span.Events(); len(events) != 1 || events[0].Name != "exception" {
+			t.Errorf("events = %v, want one exception", events)
+		}
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		w := serve(g, httptest.NewRequest("GET", "/panic", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want 500", w.Code)
+		}
+		span := sr.Ended()[2]
+		if span.Status().Code != codes.Error || len(span.Events()) != 1 {
+			t.Errorf("panic not recorded: status %v, events %v", span.Status(), span.Events())
+		}
+	})
+
+	t.Run("unmatched route", func(t *testing.T) {
+		serve(g, httptest.NewRequest("GET", "/nope", nil))
+		if name := sr.Ended()[3].Name(); name != "GET" {
+			t.Errorf("span name = %q, want the method only", name)
+		}
+	})
+}
+
+func TestTracerDisabled(t *testing.T) {
+	g := NewGin(WithTracer(&tracing.Tracer{}))
+	g.GET("/", func(ctx *gin.Context) {
+		if trace.SpanContextFromContext(ctx.Request.Context()).IsValid() {
+			t.Error("disabled tracer started a span")
+		}
+	})
+
+	if w := serve(g, httptest.NewRequest("GET", "/", nil)); w.Header().Get("traceparent") != "" {
+		t.Error("disabled tracer injected a traceparent")
+	}
+}
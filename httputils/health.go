@@ -0,0 +1,288 @@
+package httputils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusDegraded  = "degraded"
+	healthStatusUnhealthy = "unhealthy"
+	healthStatusDraining  = "draining"
+
+	defaultCheckTimeout  = 2 * time.Second
+	defaultCheckCacheTTL = time.Second
+)
+
+// CheckFunc reports whether a dependency is usable. It must honor ctx
+// cancellation, which carries the check timeout.
+type CheckFunc func(ctx context.Context) error
+
+// CheckOption configures a check registered with Health.Register.
+type CheckOption func(*check)
+
+// WithCheckTimeout bounds a single run of the check. Defaults to 2s.
+func WithCheckTimeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = d
+	}
+}
+
+// WithCheckCacheTTL sets how long a check result, success or failure, is
+// reused before the check runs again. Defaults to 1s. Caching keeps frequent
+// probe traffic from overloading the dependency.
+func WithCheckCacheTTL(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.cacheTTL = d
+	}
+}
+
+// WithCheckNonCritical reports failures of the check without failing the
+// probe; the overall status becomes "degraded" instead of "unhealthy".
+func WithCheckNonCritical() CheckOption {
+	return func(c *check) {
+		c.critical = false
+	}
+}
+
+// WithCheckLiveness includes the check in the liveness probe as well as the
+// readiness probe. Only use it for failures a process restart can fix.
+func WithCheckLiveness() CheckOption {
+	return func(c *check) {
+		c.liveness = true
+	}
+}
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	timeout  time.Duration
+	cacheTTL time.Duration
+	critical bool
+	liveness bool
+
+	// mu serializes runs so that concurrent probes share a single execution.
+	mu        sync.Mutex
+	lastErr   error
+	lastRun   time.Time
+	lastTaken time.Duration
+}
+
+type checkResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Critical  bool   `json:"critical"`
+	Duration  string `json:"duration"`
+	CheckedAt string `json:"checked_at"`
+}
+
+func (c *check) run(ctx context.Context) checkResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastRun.IsZero() || time.Since(c.lastRun) >= c.cacheTTL {
+		// Detach from the probe request so that a disconnecting client does
+		// not cache a spurious context.Canceled failure.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		start := time.Now()
+		c.lastErr = c.fn(ctx)
+		c.lastTaken = time.Since(start)
+		c.lastRun = time.Now()
+		cancel()
+	}
+
+	res := checkResult{
+		Status:    healthStatusHealthy,
+		Critical:  c.critical,
+		Duration:  c.lastTaken.String(),
+		CheckedAt: c.lastRun.UTC().Format(time.RFC3339),
+	}
+	if c.lastErr != nil {
+		res.Status = healthStatusUnhealthy
+		res.Error = c.lastErr.Error()
+	}
+	return res
+}
+
+// Health is a registry of named dependency checks served as separate
+// liveness and readiness probes. Register checks at startup, then pass the
+// registry to the router, e.g. with chimux.WithHealth or gin.WithHealth, and
+// to Serve with WithReadinessDrain(h.Drain):
+//
+//	h := httputils.NewHealth()
+//	h.Register("sqlite", httputils.PingCheck(db))
+//	h.Register("redis", rl.Ping, httputils.WithCheckNonCritical())
+//	r := chimux.NewChi(chimux.WithHealth(h))
+type Health struct {
+	mu     sync.RWMutex
+	checks []*check
+
+	draining atomic.Bool
+}
+
+// NewHealth creates an empty health registry.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// Register adds a named check. Checks are critical and readiness-only unless
+// configured otherwise. Registering a name twice replaces the previous check.
+func (h *Health) Register(name string, fn CheckFunc, opts ...CheckOption) {
+	c := &check{
+		name:     name,
+		fn:       fn,
+		timeout:  defaultCheckTimeout,
+		cacheTTL: defaultCheckCacheTTL,
+		critical: true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, existing := range h.checks {
+		if existing.name == name {
+			h.checks[i] = c
+			return
+		}
+	}
+	h.checks = append(h.checks, c)
+}
+
+// Drain permanently fails the readiness probe so that load balancers stop
+// routing new traffic to this instance ahead of shutdown. Liveness is not
+// affected. The chimux and gin Serve functions call it when a termination
+// signal is received.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// LivenessHandler serves the checks registered with WithCheckLiveness.
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, true)
+	})
+}
+
+// ReadinessHandler serves every registered check.
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, false)
+	})
+}
+
+type healthResponse struct {
+	Status    string                 `json:"status"`
+	Timestamp string                 `json:"timestamp"`
+	Checks    map[string]checkResult `json:"checks,omitempty"`
+}
+
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, liveness bool) {
+	if !liveness && h.draining.Load() {
+		writeHealthResponse(w, healthResponse{
+			Status:    healthStatusDraining,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	h.mu.RLock()
+	checks := make([]*check, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !liveness || c.liveness {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]checkResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Go(func() {
+			results[i] = c.run(r.Context())
+		})
+	}
+	wg.Wait()
+
+	resp := healthResponse{
+		Status:    healthStatusHealthy,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(checks) > 0 {
+		resp.Checks = make(map[string]checkResult, len(checks))
+	}
+	for i, c := range checks {
+		resp.Checks[c.name] = results[i]
+		if results[i].Status == healthStatusHealthy {
+			continue
+		}
+		if c.critical {
+			resp.Status = healthStatusUnhealthy
+		} else if resp.Status == healthStatusHealthy {
+			resp.Status = healthStatusDegraded
+		}
+	}
+
+	writeHealthResponse(w, resp)
+}
+
+func writeHealthResponse(w http.ResponseWriter, resp healthResponse) {
+	status := http.StatusOK
+	if resp.Status == healthStatusUnhealthy || resp.Status == healthStatusDraining {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// PingCheck adapts anything with a PingContext method, such as the *sql.DB
+// returned by sqlite.NewDB, into a CheckFunc.
+func PingCheck(p interface{ PingContext(context.Context) error }) CheckFunc {
+	return p.PingContext
+}
+
+// DialCheck returns a CheckFunc that succeeds when a connection to address
+// can be established, e.g. DialCheck("tcp", "otel-collector:4318") for an
+// OTLP endpoint.
+func DialCheck(network, address string) CheckFunc {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// HTTPCheck returns a CheckFunc that succeeds when a GET request to url
+// answers with a status code below 500.
+func HTTPCheck(url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package httputils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthProbes(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name           string
+		register       func(h *Health)
+		path           string
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"no checks", func(h *Health) {}, "/readyz", http.StatusOK, healthStatusHealthy},
+		{"all healthy", func(h *Health) {
+			h.Register("db", ok)
+		}, "/readyz", http.StatusOK, healthStatusHealthy},
+		{"critical failure", func(h *Health) {
+			h.Register("db", ok)
+			h.Register("redis", fail)
+		}, "/readyz", http.StatusServiceUnavailable, healthStatusUnhealthy},
+		{"non-critical failure", func(h *Health) {
+			h.Register("db", ok)
+			h.Register("smtp", fail, WithCheckNonCritical())
+		}, "/readyz", http.StatusOK, healthStatusDegraded},
+		{"liveness ignores readiness checks", func(h *Health) {
+			h.Register("redis", fail)
+		}, "/livez", http.StatusOK, healthStatusHealthy},
+		{"liveness runs liveness checks", func(h *Health) {
+			h.Register("deadlock", fail, WithCheckLiveness())
+		}, "/livez", http.StatusServiceUnavailable, healthStatusUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealth()
+			tt.register(h)
+			handler := h.ReadinessHandler()
+			if tt.path == "/livez" {
+				handler = h.LivenessHandler()
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected %d, got %d", tt.expectedCode, w.Code)
+			}
+			var resp healthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, resp.Status)
+			}
+		})
+	}
+}
+
+func TestHealthCheckCaching(t *testing.T) {
+	var calls atomic.Int32
+	h := NewHealth()
+	h.Register("slow", func(ctx context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, WithCheckCacheTTL(time.Minute))
+
+	handler := h.ReadinessHandler()
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/readyz", nil))
+		})
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected check to run once, ran %d times", got)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	h := NewHealth()
+	h.Register("hung", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithCheckTimeout(10*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestHealthDrain(t *testing.T) {
+	h := NewHealth()
+	h.Drain()
+
+	w := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readiness: expected 503, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), healthStatusDraining) {
+		t.Errorf("readiness body = %q, want the draining status", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.LivenessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("liveness: expected 200, got %d", w.Code)
+	}
+}
@@ -1,66 +1,119 @@
-// Package httputils provides HTTP middleware utilities for the standard net/http package.
+// Package httputils provides HTTP middleware utilities for the standard net/http
+// package, the access log policy shared by the chimux and gin middleware, and
+// a graceful server runner for any http.Handler.
 package httputils
 
 import (
-	"fmt"
-	"log"
 	"net/http"
-	"strings"
+	"regexp"
 	"time"
+
+	"github.com/meysam81/x/logging"
 )
 
-// LoggingMiddleware wraps an http.HandlerFunc to log the method, path,
-// status code, request/response headers, and latency for each request.
-// Authorization headers are omitted from the log output.
-func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+type logOptions struct {
+	logger *logging.Logger
+	policy LogPolicy
+}
+
+// LogOption configures LoggingMiddleware and LoggingHandler.
+type LogOption func(*logOptions)
+
+// WithLogger sets the logger of the access log. Defaults to
+// logging.NewLogger().
+func WithLogger(l *logging.Logger) LogOption {
+	return func(o *logOptions) {
+		o.logger = l
+	}
+}
+
+// WithLogPolicy sets which headers and query parameters are logged and which
+// are masked. The default policy logs a curated set of request headers and
+// the query string, masking sensitive values and JSON Web Tokens.
+func WithLogPolicy(p LogPolicy) LogOption {
+	return func(o *logOptions) {
+		o.policy = p
+	}
+}
+
+// LoggingMiddleware wraps an http.HandlerFunc to write one structured access
+// log line per request. See LoggingHandler.
+func LoggingMiddleware(next http.HandlerFunc, opts ...LogOption) http.HandlerFunc {
+	return LoggingHandler(next, opts...).ServeHTTP
+}
+
+// LoggingHandler wraps next to write one structured access log line per
+// request with the method, path, status, response bytes, duration, remote
+// address, user agent and the headers and query parameters allowed by the
+// log policy, sensitive values masked. Responses are logged at Error level
+// for 5xx, Warn for 4xx and Info otherwise.
+func LoggingHandler(next http.Handler, opts ...LogOption) http.Handler {
+	o := &logOptions{
+		policy: LogPolicy{RedactPatterns: []*regexp.Regexp{JWTPattern}},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	logger := o.logger
+	if logger == nil {
+		l := logging.NewLogger()
+		logger = &l
+	}
+	policy := &o.policy
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
 
 		rw := newResponseWriter(w)
 
-		var reqHeaders []string
-		for name, values := range r.Header {
-			if strings.EqualFold(name, "Authorization") {
-				continue
-			}
-			for _, value := range values {
-				reqHeaders = append(reqHeaders, fmt.Sprintf("%s: %s", name, value))
-			}
-		}
-
-		next(rw, r)
-
-		var respHeaders []string
-		for name, values := range rw.Header() {
-			for _, value := range values {
-				respHeaders = append(respHeaders, fmt.Sprintf("%s: %s", name, value))
-			}
-		}
-
-		duration := time.Since(startTime)
-		log.Printf(
-			"%s %s %s %v %v - %d - %v",
-			r.RemoteAddr,
-			r.Method,
-			r.URL.Path,
-			strings.Join(reqHeaders, ", "),
-			strings.Join(respHeaders, ", "),
-			rw.statusCode,
-			duration,
-		)
-	}
+		next.ServeHTTP(rw, r)
+
+		event := logger.WithLevel(AccessLogLevel(rw.statusCode)).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rw.statusCode).
+			Int("bytes", rw.bytes).
+			Str("duration", time.Since(startTime).String()).
+			Str("remote_addr", r.RemoteAddr).
+			Str("user_agent", r.UserAgent())
+
+		policy.AddFields(event, r, rw.Header()).Send()
+	})
 }
 
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	bytes       int
+	wroteHeader bool
 }
 
 func newResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{w, http.StatusOK}
+	return &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+	if !rw.wroteHeader && code >= http.StatusOK {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += n
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (rw *responseWriter) Flush() {
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
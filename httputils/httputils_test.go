@@ -0,0 +1,161 @@
+package httputils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func logEntry(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func TestLoggingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	h := LoggingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "hello")
+	}), WithLogger(&logger), WithLogPolicy(LogPolicy{
+		HeaderMode:      HeaderLogAll,
+		ResponseHeaders: LowerSet(nil, "Set-Cookie"),
+	}))
+
+	r := httptest.NewRequest("POST", "/items?token=abc&page=2", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("X-Api-Key", "secret")
+	r.Header.Set("X-Custom", "visible")
+	r.Header.Set("User-Agent", "test-agent")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	entry := logEntry(t, &buf)
+	expected := map[string]any{
+		"level":       "info",
+		"method":      "POST",
+		"path":        "/items",
+		"status":      float64(http.StatusCreated),
+		"bytes":       float64(5),
+		"remote_addr": "192.0.2.1:1234",
+		"user_agent":  "test-agent",
+	}
+	for k, want := range expected {
+		if entry[k] != want {
+			t.Errorf("%s = %v, want %v", k, entry[k], want)
+		}
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("duration missing")
+	}
+
+	headers, _ := entry["headers"].(map[string]any)
+	for _, name := range []string{"authorization", "cookie", "x-api-key"} {
+		if headers[name] != Mask {
+			t.Errorf("headers[%s] = %v, want masked", name, headers[name])
+		}
+	}
+	if headers["x-custom"] != "visible" {
+		t.Errorf("headers[x-custom] = %v", headers["x-custom"])
+	}
+
+	query, _ := entry["query"].(map[string]any)
+	if query["token"] != Mask || query["page"] != "2" {
+		t.Errorf("query = %v", query)
+	}
+
+	response, _ := entry["response_headers"].(map[string]any)
+	if response["set-cookie"] != Mask {
+		t.Errorf("response_headers = %v, want set-cookie masked", response)
+	}
+}
+
+func TestLoggingHandlerDefaultPolicy(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	h := LoggingHandler(http.NotFoundHandler(), WithLogger(&logger))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("Referer", "https://example.com/?t=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	entry := logEntry(t, &buf)
+	headers, _ := entry["headers"].(map[string]any)
+	if _, ok := headers["cookie"]; ok {
+		t.Error("cookie is not in the curated set but was logged")
+	}
+	if headers["content-type"] != "text/plain" {
+		t.Errorf("headers[content-type] = %v", headers["content-type"])
+	}
+	if headers["referer"] != "https://example.com/?t="+Mask {
+		t.Errorf("headers[referer] = %v, want the JWT masked", headers["referer"])
+	}
+	if _, ok := entry["response_headers"]; ok {
+		t.Error("response headers logged without being selected")
+	}
+}
+
+func TestLoggingMiddlewareLevels(t *testing.T) {
+	tests := []struct {
+		status int
+		level  string
+	}{
+		{http.StatusOK, "info"},
+		{http.StatusNoContent, "info"},
+		{http.StatusFound, "info"},
+		{http.StatusBadRequest, "warn"},
+		{http.StatusNotFound, "warn"},
+		{http.StatusInternalServerError, "error"},
+		{http.StatusServiceUnavailable, "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := zerolog.New(&buf)
+
+			h := LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}, WithLogger(&logger), WithLogPolicy(LogPolicy{HeaderMode: HeaderLogNone}))
+			h(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			entry := logEntry(t, &buf)
+			if entry["level"] != tt.level || entry["status"] != float64(tt.status) {
+				t.Errorf("level = %v, status = %v, want %s and %d", entry["level"], entry["status"], tt.level, tt.status)
+			}
+			if _, ok := entry["headers"]; ok {
+				t.Error("headers logged in none mode")
+			}
+		})
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	logger := zerolog.Nop()
+	h := LoggingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "chunk")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	}), WithLogger(&logger))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.Flushed {
+		t.Error("response not flushed")
+	}
+}
@@ -0,0 +1,283 @@
+package httputils
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Mask replaces sensitive values in access logs.
+const Mask = "TRUNCATED"
+
+// JWTPattern matches JSON Web Tokens. It is a default redaction pattern, so
+// tokens are masked wherever they appear in logged header and query values.
+var JWTPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// HeaderLogMode selects which request headers are logged.
+type HeaderLogMode int
+
+const (
+	// HeaderLogDefault logs a curated set of headers plus LogPolicy.Headers.
+	HeaderLogDefault HeaderLogMode = iota
+	// HeaderLogAll logs every request header.
+	HeaderLogAll
+	// HeaderLogNone logs no request headers.
+	HeaderLogNone
+)
+
+// defaultLogHeaders is the curated set of headers logged in the default mode.
+// Drawn from RFC 9110 (HTTP Semantics), RFC 7239 (Forwarded), W3C Trace Context,
+// and common de facto standards (X-Request-Id).
+// User-Agent is always logged as a top-level structured field.
+var defaultLogHeaders = map[string]struct{}{
+	"accept":            {},
+	"content-length":    {},
+	"content-type":      {},
+	"forwarded":         {},
+	"host":              {},
+	"origin":            {},
+	"referer":           {},
+	"traceparent":       {},
+	"x-forwarded-for":   {},
+	"x-forwarded-proto": {},
+	"x-request-id":      {},
+}
+
+var sensitiveHeaders = map[string]struct{}{
+	"authorization":       {},
+	"cookie":              {},
+	"set-cookie":          {},
+	"x-api-key":           {},
+	"x-auth-token":        {},
+	"x-access-token":      {},
+	"authentication":      {},
+	"proxy-authorization": {},
+}
+
+// sensitiveQueryParams are query parameter names whose values are masked.
+var sensitiveQueryParams = map[string]struct{}{
+	"access_token":  {},
+	"api_key":       {},
+	"apikey":        {},
+	"client_secret": {},
+	"code":          {},
+	"password":      {},
+	"refresh_token": {},
+	"secret":        {},
+	"sig":           {},
+	"signature":     {},
+	"token":         {},
+}
+
+// IsSensitiveHeader reports whether header is one of the built-in sensitive
+// headers, such as Authorization or Cookie, whose values are always masked.
+func IsSensitiveHeader(header string) bool {
+	_, ok := sensitiveHeaders[strings.ToLower(header)]
+	return ok
+}
+
+// LogPolicy decides which request and response data end up in access logs
+// and masks secrets in it. It is shared by the chimux and gin logging
+// middleware so both log the same fields the same way. Map keys must be
+// lowercase; use LowerSet to build them.
+type LogPolicy struct {
+	HeaderMode HeaderLogMode
+	// Headers are logged in addition to the curated default set.
+	Headers map[string]struct{}
+	// ResponseHeaders are the response headers to log. None by default.
+	ResponseHeaders map[string]struct{}
+	// SensitiveHeaders and SensitiveQueryParams are masked in addition to
+	// the built-in sets.
+	SensitiveHeaders     map[string]struct{}
+	SensitiveQueryParams map[string]struct{}
+	// RedactPatterns are masked wherever they match a logged value.
+	RedactPatterns []*regexp.Regexp
+	DisableQuery   bool
+	// QueryKey names the field holding the query dict. Defaults to "query".
+	QueryKey string
+}
+
+// LowerSet adds the lowercased names to set, allocating it if nil.
+func LowerSet(set map[string]struct{}, names ...string) map[string]struct{} {
+	if set == nil {
+		set = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		set[strings.ToLower(name)] = struct{}{}
+	}
+	return set
+}
+
+// ShouldLogHeader reports whether the request header is logged.
+func (p *LogPolicy) ShouldLogHeader(header string) bool {
+	switch p.HeaderMode {
+	case HeaderLogNone:
+		return false
+	case HeaderLogAll:
+		return true
+	default: // HeaderLogDefault
+		key := strings.ToLower(header)
+		if _, ok := defaultLogHeaders[key]; ok {
+			return true
+		}
+		_, ok := p.Headers[key]
+		return ok
+	}
+}
+
+// ShouldLogResponseHeader reports whether the response header is logged.
+func (p *LogPolicy) ShouldLogResponseHeader(header string) bool {
+	_, ok := p.ResponseHeaders[strings.ToLower(header)]
+	return ok
+}
+
+// IsSensitiveHeader reports whether the value of header is masked.
+func (p *LogPolicy) IsSensitiveHeader(header string) bool {
+	key := strings.ToLower(header)
+	if _, ok := sensitiveHeaders[key]; ok {
+		return true
+	}
+	_, ok := p.SensitiveHeaders[key]
+	return ok
+}
+
+// IsSensitiveQueryParam reports whether the value of param is masked.
+func (p *LogPolicy) IsSensitiveQueryParam(param string) bool {
+	key := strings.ToLower(param)
+	if _, ok := sensitiveQueryParams[key]; ok {
+		return true
+	}
+	_, ok := p.SensitiveQueryParams[key]
+	return ok
+}
+
+// Redact masks every match of the redaction patterns in value.
+func (p *LogPolicy) Redact(value string) string {
+	for _, re := range p.RedactPatterns {
+		value = re.ReplaceAllString(value, Mask)
+	}
+	return value
+}
+
+// Dict renders the selected entries of values as a structured log dict with
+// sorted keys, lowercased if lowerKeys is set. Sensitive entries are masked
+// and the rest pass through the redaction patterns. It returns nil if nothing
+// is selected.
+func (p *LogPolicy) Dict(values map[string][]string, lowerKeys bool, include, sensitive func(string) bool) *zerolog.Event {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if include(k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	d := zerolog.Dict()
+	for _, k := range keys {
+		value := Mask
+		if !sensitive(k) {
+			value = p.Redact(strings.Join(values[k], "; "))
+		}
+		if lowerKeys {
+			k = strings.ToLower(k)
+		}
+		d = d.Str(k, value)
+	}
+	return d
+}
+
+// AddFields adds the "headers", "query" (or QueryKey) and "response_headers"
+// dicts allowed by the policy to event.
+func (p *LogPolicy) AddFields(event *zerolog.Event, r *http.Request, responseHeader http.Header) *zerolog.Event {
+	// Skip header iteration when headers are disabled.
+	if p.HeaderMode != HeaderLogNone {
+		if d := p.Dict(r.Header, true, p.ShouldLogHeader, p.IsSensitiveHeader); d != nil {
+			event = event.Dict("headers", d)
+		}
+	}
+
+	if !p.DisableQuery && r.URL.RawQuery != "" {
+		all := func(string) bool { return true }
+		if d := p.Dict(r.URL.Query(), false, all, p.IsSensitiveQueryParam); d != nil {
+			key := p.QueryKey
+			if key == "" {
+				key = "query"
+			}
+			event = event.Dict(key, d)
+		}
+	}
+
+	if len(p.ResponseHeaders) > 0 {
+		if d := p.Dict(responseHeader, true, p.ShouldLogResponseHeader, p.IsSensitiveHeader); d != nil {
+			event = event.Dict("response_headers", d)
+		}
+	}
+
+	return event
+}
+
+// AccessLogLevel returns the level of the access log line of a response:
+// Error for 5xx, Warn for 4xx and Info otherwise.
+func AccessLogLevel(status int) zerolog.Level {
+	switch {
+	case status >= 500:
+		return zerolog.ErrorLevel
+	case status >= 400:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// LogSampler decides which successful requests are logged. Errors (4xx/5xx)
+// and slow requests are always logged.
+type LogSampler struct {
+	// Rate is the fraction of successful requests logged, from 0 to 1.
+	Rate float64
+	// RouteRates overrides Rate by route pattern.
+	RouteRates map[string]float64
+	// SlowThreshold marks requests at least this slow; zero disables it.
+	SlowThreshold time.Duration
+}
+
+// IsSlow reports whether a request that took duration is slow.
+func (s *LogSampler) IsSlow(duration time.Duration) bool {
+	return s.SlowThreshold > 0 && duration >= s.SlowThreshold
+}
+
+// ShouldLog decides whether a request for route is logged. Successful
+// requests are sampled at the rate of their route, or the global rate if the
+// route has none.
+func (s *LogSampler) ShouldLog(route string, status int, duration time.Duration) bool {
+	if status >= 400 {
+		return true
+	}
+
+	if s.IsSlow(duration) {
+		return true
+	}
+
+	rate := s.Rate
+	if len(s.RouteRates) > 0 {
+		if routeRate, ok := s.RouteRates[route]; ok {
+			rate = routeRate
+		}
+	}
+
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return rand.Float64() < rate
+	}
+}
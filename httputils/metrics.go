@@ -0,0 +1,270 @@
+package httputils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// sizeBuckets covers payloads from 100B up to 1GB.
+var sizeBuckets = prometheus.ExponentialBuckets(100, 10, 8)
+
+// MetricsConfig configures where and how NewMetrics registers the HTTP
+// metrics.
+type MetricsConfig struct {
+	// Registerer receives the metrics. Defaults to
+	// prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Namespace prefixes every metric name, e.g. "myapp_http_requests_total".
+	Namespace string
+	// ConstLabels are attached to every metric.
+	ConstLabels prometheus.Labels
+	// LatencyBuckets are the classic histogram buckets, in seconds, of the
+	// request duration. Defaults to prometheus.DefBuckets unless native
+	// histograms are enabled.
+	LatencyBuckets []float64
+	// NativeHistogramBucketFactor records the request duration as a native
+	// histogram with this bucket growth factor when greater than 1.
+	NativeHistogramBucketFactor float64
+}
+
+// Metrics holds the HTTP server metrics shared by the chimux and gin
+// middleware, so that services built on either framework expose the same
+// names and labels and work with the same dashboards and alerts. The path
+// label is expected to be a route pattern rather than the raw URL path to keep
+// its cardinality bounded.
+type Metrics struct {
+	// Traffic: Rate of requests
+	requestsTotal *prometheus.CounterVec
+
+	// Latency: Time taken to serve requests
+	requestDuration *prometheus.HistogramVec
+
+	// Errors: Rate of requests that fail
+	responseStatus *prometheus.CounterVec
+	panicsTotal    *prometheus.CounterVec
+
+	// Payload: Request and response body sizes
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+
+	// Saturation: Resource utilization
+	requestsInFlight  prometheus.Gauge
+	activeConnections prometheus.Gauge
+}
+
+// NewMetrics registers the HTTP metrics with cfg.Registerer. If identical
+// metrics are already registered, e.g. because two routers share a registry,
+// the existing collectors are reused instead of panicking on duplicate
+// registration.
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	reg := cfg.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	durationOpts := prometheus.HistogramOpts{
+		Namespace:   cfg.Namespace,
+		Name:        "http_request_duration_seconds",
+		Help:        "HTTP request duration in seconds",
+		ConstLabels: cfg.ConstLabels,
+		Buckets:     cfg.LatencyBuckets,
+	}
+	if cfg.NativeHistogramBucketFactor > 1 {
+		durationOpts.NativeHistogramBucketFactor = cfg.NativeHistogramBucketFactor
+		durationOpts.NativeHistogramMaxBucketNumber = 160
+		durationOpts.NativeHistogramMinResetDuration = time.Hour
+	} else if durationOpts.Buckets == nil {
+		durationOpts.Buckets = prometheus.DefBuckets
+	}
+
+	return &Metrics{
+		requestsTotal: register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_requests_total",
+				Help:        "Total number of HTTP requests",
+				ConstLabels: cfg.ConstLabels,
+			},
+			[]string{"method", "path", "status_code"},
+		)),
+
+		requestDuration: register(reg, prometheus.NewHistogramVec(
+			durationOpts,
+			[]string{"method", "path", "status_code"},
+		)),
+
+		responseStatus: register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_response_status_total",
+				Help:        "Total number of HTTP responses by status code",
+				ConstLabels: cfg.ConstLabels,
+			},
+			[]string{"status_code", "status_class"},
+		)),
+
+		panicsTotal: register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_panics_total",
+				Help:        "Total number of panics recovered from HTTP handlers",
+				ConstLabels: cfg.ConstLabels,
+			},
+			[]string{"method", "path"},
+		)),
+
+		requestSize: register(reg, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_request_size_bytes",
+				Help:        "HTTP request body size in bytes",
+				ConstLabels: cfg.ConstLabels,
+				Buckets:     sizeBuckets,
+			},
+			[]string{"method", "path"},
+		)),
+
+		responseSize: register(reg, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_response_size_bytes",
+				Help:        "HTTP response body size in bytes",
+				ConstLabels: cfg.ConstLabels,
+				Buckets:     sizeBuckets,
+			},
+			[]string{"method", "path"},
+		)),
+
+		requestsInFlight: register(reg, prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_requests_in_flight",
+				Help:        "Number of HTTP requests currently being processed",
+				ConstLabels: cfg.ConstLabels,
+			},
+		)),
+
+		activeConnections: register(reg, prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cfg.Namespace,
+				Name:        "http_active_connections",
+				Help:        "Number of active HTTP connections",
+				ConstLabels: cfg.ConstLabels,
+			},
+		)),
+	}
+}
+
+// register registers c with reg, returning the already registered collector
+// if an identical one exists.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// MetricsHandler serves the metrics gathered from gatherer or, if it is nil,
+// from registerer when that is also a prometheus.Gatherer, such as a
+// *prometheus.Registry. It falls back to the default registry.
+func MetricsHandler(registerer prometheus.Registerer, gatherer prometheus.Gatherer) http.Handler {
+	if gatherer == nil {
+		if g, ok := registerer.(prometheus.Gatherer); ok {
+			gatherer = g
+		}
+	}
+	if gatherer == nil {
+		return promhttp.Handler()
+	}
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a served request: its count, duration, status and
+// body sizes.
+func (m *Metrics) ObserveRequest(method, path string, statusCode int, duration time.Duration, requestSize, responseSize int64) {
+	statusStr := strconv.Itoa(statusCode)
+
+	m.requestsTotal.WithLabelValues(method, path, statusStr).Inc()
+	m.requestDuration.WithLabelValues(method, path, statusStr).Observe(duration.Seconds())
+	m.responseStatus.WithLabelValues(statusStr, StatusClass(statusCode)).Inc()
+
+	m.requestSize.WithLabelValues(method, path).Observe(float64(requestSize))
+	m.responseSize.WithLabelValues(method, path).Observe(float64(responseSize))
+}
+
+// ObservePanic counts a panic recovered from the handler of path.
+func (m *Metrics) ObservePanic(method, path string) {
+	m.panicsTotal.WithLabelValues(method, path).Inc()
+}
+
+// AddInFlight adds delta to the number of requests being served.
+func (m *Metrics) AddInFlight(delta float64) {
+	m.requestsInFlight.Add(delta)
+}
+
+// ConnState is an http.Server ConnState callback tracking live connections.
+func (m *Metrics) ConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		m.activeConnections.Inc()
+	case http.StateHijacked, http.StateClosed:
+		m.activeConnections.Dec()
+	}
+}
+
+// StatusClass returns the class of statusCode, e.g. "4xx".
+func StatusClass(statusCode int) string {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return "2xx"
+	case statusCode >= 300 && statusCode < 400:
+		return "3xx"
+	case statusCode >= 400 && statusCode < 500:
+		return "4xx"
+	case statusCode >= 500:
+		return "5xx"
+	default:
+		return "1xx"
+	}
+}
+
+// CountRequestBody wraps the body of r to count the bytes the handler reads.
+// The returned function reports that count once the handler is done, or the
+// declared Content-Length if the handler did not read the body.
+func CountRequestBody(r *http.Request) func() int64 {
+	body := &countingReader{ReadCloser: r.Body}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = body
+	}
+	return func() int64 {
+		if body.n == 0 && r.ContentLength > 0 {
+			return r.ContentLength
+		}
+		return body.n
+	}
+}
+
+// countingReader counts the request body bytes consumed by the handler.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
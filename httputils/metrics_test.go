@@ -0,0 +1,114 @@
+package httputils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cfg := MetricsConfig{
+		Registerer:     reg,
+		Namespace:      "myapp",
+		ConstLabels:    prometheus.Labels{"listener": "public"},
+		LatencyBuckets: []float64{0.1, 1},
+	}
+	m := NewMetrics(cfg)
+	// A second router on the same registry shares the collectors.
+	NewMetrics(cfg).ObserveRequest("GET", "/users/{id}", http.StatusOK, 50*time.Millisecond, 0, 10)
+	m.ObserveRequest("POST", "/users", http.StatusBadRequest, 2*time.Second, 5, 0)
+	m.ObservePanic("GET", "/panic")
+
+	expected := `
+# HELP myapp_http_requests_total Total number of HTTP requests
+# TYPE myapp_http_requests_total counter
+myapp_http_requests_total{listener="public",method="GET",path="/users/{id}",status_code="200"} 1
+myapp_http_requests_total{listener="public",method="POST",path="/users",status_code="400"} 1
+# HELP myapp_http_request_duration_seconds HTTP request duration in seconds
+# TYPE myapp_http_request_duration_seconds histogram
+myapp_http_request_duration_seconds_bucket{listener="public",method="GET",path="/users/{id}",status_code="200",le="0.1"} 1
+myapp_http_request_duration_seconds_bucket{listener="public",method="GET",path="/users/{id}",status_code="200",le="1"} 1
+myapp_http_request_duration_seconds_bucket{listener="public",method="GET",path="/users/{id}",status_code="200",le="+Inf"} 1
+myapp_http_request_duration_seconds_sum{listener="public",method="GET",path="/users/{id}",status_code="200"} 0.05
+myapp_http_request_duration_seconds_count{listener="public",method="GET",path="/users/{id}",status_code="200"} 1
+myapp_http_request_duration_seconds_bucket{listener="public",method="POST",path="/users",status_code="400",le="0.1"} 0
+myapp_http_request_duration_seconds_bucket{listener="public",method="POST",path="/users",status_code="400",le="1"} 0
+myapp_http_request_duration_seconds_bucket{listener="public",method="POST",path="/users",status_code="400",le="+Inf"} 1
+myapp_http_request_duration_seconds_sum{listener="public",method="POST",path="/users",status_code="400"} 2
+myapp_http_request_duration_seconds_count{listener="public",method="POST",path="/users",status_code="400"} 1
+# HELP myapp_http_response_status_total Total number of HTTP responses by status code
+# TYPE myapp_http_response_status_total counter
+myapp_http_response_status_total{listener="public",status_class="2xx",status_code="200"} 1
+myapp_http_response_status_total{listener="public",status_class="4xx",status_code="400"} 1
+# HELP myapp_http_panics_total Total number of panics recovered from HTTP handlers
+# TYPE myapp_http_panics_total counter
+myapp_http_panics_total{listener="public",method="GET",path="/panic"} 1
+`
+	names := []string{"myapp_http_requests_total", "myapp_http_request_duration_seconds", "myapp_http_response_status_total", "myapp_http_panics_total"}
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+	MetricsHandler(reg, nil).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "myapp_http_response_size_bytes") {
+		t.Error("MetricsHandler does not serve the registry")
+	}
+}
+
+func TestMetricsGauges(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(MetricsConfig{Registerer: reg})
+
+	m.AddInFlight(2)
+	m.AddInFlight(-1)
+	for _, state := range []http.ConnState{http.StateNew, http.StateNew, http.StateActive, http.StateClosed} {
+		m.ConnState(nil, state)
+	}
+
+	expected := `
+# HELP http_active_connections Number of active HTTP connections
+# TYPE http_active_connections gauge
+http_active_connections 1
+# HELP http_requests_in_flight Number of HTTP requests currently being processed
+# TYPE http_requests_in_flight gauge
+http_requests_in_flight 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_active_connections", "http_requests_in_flight"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCountRequestBody(t *testing.T) {
+	t.Run("read", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+		r.ContentLength = -1
+		size := CountRequestBody(r)
+		_, _ = io.ReadAll(r.Body)
+		if got := size(); got != 5 {
+			t.Errorf("size = %d, want 5", got)
+		}
+	})
+
+	t.Run("unread", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+		if got := CountRequestBody(r)(); got != 5 {
+			t.Errorf("size = %d, want the Content-Length", got)
+		}
+	})
+}
+
+func TestStatusClass(t *testing.T) {
+	for code, want := range map[int]string{101: "1xx", 204: "2xx", 301: "3xx", 404: "4xx", 503: "5xx"} {
+		if got := StatusClass(code); got != want {
+			t.Errorf("StatusClass(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package httputils
+
+import "github.com/google/uuid"
+
+// MaxRequestIDLength bounds client supplied request IDs accepted as-is.
+const MaxRequestIDLength = 128
+
+// NewUUIDv7 generates a time-ordered UUIDv7 request ID. It is the default
+// request ID generator of the chimux and gin middleware.
+func NewUUIDv7() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// IsValidRequestID accepts client supplied IDs made of at most
+// MaxRequestIDLength URL-safe characters, so that they cannot inject content
+// into logs.
+func IsValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
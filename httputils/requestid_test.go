@@ -0,0 +1,36 @@
+package httputils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewUUIDv7(t *testing.T) {
+	a, b := NewUUIDv7(), NewUUIDv7()
+	if a == b || !IsValidRequestID(a) {
+		t.Errorf("NewUUIDv7() = %q, %q", a, b)
+	}
+	if a[14] != '7' {
+		t.Errorf("NewUUIDv7() = %q, want version 7", a)
+	}
+}
+
+func TestIsValidRequestID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"abc-123_x.y:z", true},
+		{strings.Repeat("a", MaxRequestIDLength), true},
+		{"", false},
+		{strings.Repeat("a", MaxRequestIDLength+1), false},
+		{"bad id", false},
+		{"line\nbreak", false},
+		{"quote\"", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidRequestID(tt.id); got != tt.valid {
+			t.Errorf("IsValidRequestID(%q) = %v, want %v", tt.id, got, tt.valid)
+		}
+	}
+}
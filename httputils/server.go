@@ -0,0 +1,324 @@
+package httputils
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/meysam81/x/logging"
+)
+
+// serverOptions configures the http.Server started by Serve.
+type serverOptions struct {
+	addr              string
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+
+	tlsConfig   *tls.Config
+	tlsCertFile string
+	tlsKeyFile  string
+
+	adminAddr         string
+	adminHandler      http.Handler
+	adminWriteTimeout time.Duration
+
+	logger          *logging.Logger
+	connState       func(net.Conn, http.ConnState)
+	drain           func()
+	drainDelay      time.Duration
+	shutdownTimeout time.Duration
+	shutdownHooks   []func(context.Context) error
+}
+
+// ServerOption configures Serve.
+type ServerOption func(*serverOptions)
+
+func defaultServerOptions() serverOptions {
+	return serverOptions{
+		addr:              ":8080",
+		readTimeout:       15 * time.Second,
+		readHeaderTimeout: 5 * time.Second,
+		writeTimeout:      30 * time.Second,
+		idleTimeout:       120 * time.Second,
+		maxHeaderBytes:    1 << 20,
+		adminWriteTimeout: 60 * time.Second,
+		drainDelay:        5 * time.Second,
+		shutdownTimeout:   15 * time.Second,
+	}
+}
+
+// WithAddr sets the TCP address Serve listens on. Defaults to ":8080".
+func WithAddr(addr string) ServerOption {
+	return func(o *serverOptions) {
+		o.addr = addr
+	}
+}
+
+// WithReadTimeout sets http.Server.ReadTimeout. Defaults to 15s.
+func WithReadTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.readTimeout = d
+	}
+}
+
+// WithReadHeaderTimeout sets http.Server.ReadHeaderTimeout. Defaults to 5s.
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.readHeaderTimeout = d
+	}
+}
+
+// WithWriteTimeout sets http.Server.WriteTimeout. Defaults to 30s.
+func WithWriteTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.writeTimeout = d
+	}
+}
+
+// WithIdleTimeout sets http.Server.IdleTimeout. Defaults to 120s.
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes sets http.Server.MaxHeaderBytes. Defaults to 1MB.
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(o *serverOptions) {
+		o.maxHeaderBytes = n
+	}
+}
+
+// WithTLSConfig serves HTTPS with cfg, which must provide the certificates,
+// e.g. through Certificates or GetCertificate. Combine it with WithTLSCertFile
+// to load them from disk instead.
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(o *serverOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithTLSCertFile serves HTTPS with the PEM encoded certificate chain and
+// private key read from certFile and keyFile.
+func WithTLSCertFile(certFile, keyFile string) ServerOption {
+	return func(o *serverOptions) {
+		o.tlsCertFile = certFile
+		o.tlsKeyFile = keyFile
+	}
+}
+
+// WithAdminListener serves handler, typically metrics and debug endpoints, on
+// a second plain HTTP listener on addr, e.g. "127.0.0.1:9090". It is started
+// and shut down together with the main server.
+func WithAdminListener(addr string, handler http.Handler) ServerOption {
+	return func(o *serverOptions) {
+		o.adminAddr = addr
+		o.adminHandler = handler
+	}
+}
+
+// WithAdminWriteTimeout sets http.Server.WriteTimeout of the admin listener.
+// Defaults to 60s, which leaves room for the default 30s CPU profile of
+// net/http/pprof.
+func WithAdminWriteTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.adminWriteTimeout = d
+	}
+}
+
+// WithServerLogger sets the logger of the lifecycle messages. Defaults to
+// logging.NewLogger().
+func WithServerLogger(l *logging.Logger) ServerOption {
+	return func(o *serverOptions) {
+		o.logger = l
+	}
+}
+
+// WithConnState sets http.Server.ConnState of the main server, e.g. to keep a
+// connection gauge up to date.
+func WithConnState(fn func(net.Conn, http.ConnState)) ServerOption {
+	return func(o *serverOptions) {
+		o.connState = fn
+	}
+}
+
+// WithReadinessDrain registers fn to fail the readiness probe as soon as
+// shutdown begins, before the drain delay, e.g. the Drain method of a health
+// registry.
+func WithReadinessDrain(fn func()) ServerOption {
+	return func(o *serverOptions) {
+		o.drain = fn
+	}
+}
+
+// WithDrainDelay sets how long Serve keeps serving after failing the
+// readiness probe, giving load balancers time to deregister the instance
+// before connections are closed. Defaults to 5s.
+func WithDrainDelay(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.drainDelay = d
+	}
+}
+
+// WithShutdownTimeout bounds how long Serve waits for in-flight requests to
+// complete and for shutdown hooks to return. Defaults to 15s.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithShutdownHook registers fn to run after the HTTP server has shut down,
+// e.g. WithShutdownHook(tracer.Shutdown) to flush pending spans. Hooks run in
+// registration order.
+func WithShutdownHook(fn func(context.Context) error) ServerOption {
+	return func(o *serverOptions) {
+		o.shutdownHooks = append(o.shutdownHooks, fn)
+	}
+}
+
+// Serve runs handler, such as a chi router or a gin engine, on an http.Server
+// with production timeouts until ctx is cancelled or the process receives
+// SIGINT or SIGTERM. It then fails the readiness probe given with
+// WithReadinessDrain, waits for the drain delay, gracefully shuts the server
+// down and runs the shutdown hooks:
+//
+//	err := httputils.Serve(ctx, engine,
+//		httputils.WithAddr(":8443"),
+//		httputils.WithTLSCertFile("tls.crt", "tls.key"),
+//		httputils.WithAdminListener("127.0.0.1:9090", promhttp.Handler()),
+//		httputils.WithShutdownHook(tracer.Shutdown),
+//	)
+//
+// A second signal received while draining terminates the process immediately.
+func Serve(ctx context.Context, handler http.Handler, opts ...ServerOption) error {
+	o := defaultServerOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	logger := o.logger
+	if logger == nil {
+		l := logging.NewLogger()
+		logger = &l
+	}
+
+	srv := &http.Server{
+		Addr:              o.addr,
+		Handler:           handler,
+		ReadTimeout:       o.readTimeout,
+		ReadHeaderTimeout: o.readHeaderTimeout,
+		WriteTimeout:      o.writeTimeout,
+		IdleTimeout:       o.idleTimeout,
+		MaxHeaderBytes:    o.maxHeaderBytes,
+		TLSConfig:         o.tlsConfig,
+		ConnState:         o.connState,
+	}
+	useTLS := o.tlsConfig != nil || o.tlsCertFile != ""
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", o.addr)
+	if err != nil {
+		return err
+	}
+
+	servers := []*http.Server{srv}
+	listeners := []net.Listener{ln}
+	if o.adminAddr != "" {
+		adminLn, err := net.Listen("tcp", o.adminAddr)
+		if err != nil {
+			_ = ln.Close()
+			return err
+		}
+		servers = append(servers, newAdminServer(&o))
+		listeners = append(listeners, adminLn)
+	}
+
+	errCh := make(chan error, len(servers))
+	for i, s := range servers {
+		go func() {
+			admin := i > 0
+			secure := useTLS && !admin
+			logger.Info().Str("addr", listeners[i].Addr().String()).Bool("admin", admin).Bool("tls", secure).Msg("http server listening")
+			if secure {
+				errCh <- s.ServeTLS(listeners[i], o.tlsCertFile, o.tlsKeyFile)
+				return
+			}
+			errCh <- s.Serve(listeners[i])
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		for _, s := range servers {
+			_ = s.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	// Restore default signal handling so a second signal kills the process.
+	stop()
+
+	logger.Info().Msg("shutdown signal received")
+
+	if o.drain != nil {
+		o.drain()
+		logger.Info().Msg("readiness probe set to failing")
+	}
+
+	if o.drainDelay > 0 {
+		logger.Info().Str("delay", o.drainDelay.String()).Msg("waiting for load balancers to drain traffic")
+		time.Sleep(o.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), o.shutdownTimeout)
+	defer cancel()
+
+	logger.Info().Msg("shutting down http server")
+	err = nil
+	for _, s := range servers {
+		if shutdownErr := s.Shutdown(shutdownCtx); shutdownErr != nil {
+			logger.Error().Err(shutdownErr).Msg("failed shutting down the http server gracefully")
+			err = errors.Join(err, shutdownErr)
+		}
+	}
+	for range servers {
+		if serveErr := <-errCh; !errors.Is(serveErr, http.ErrServerClosed) {
+			err = errors.Join(err, serveErr)
+		}
+	}
+
+	for _, hook := range o.shutdownHooks {
+		if hookErr := hook(shutdownCtx); hookErr != nil {
+			logger.Error().Err(hookErr).Msg("failed running shutdown hook")
+			err = errors.Join(err, hookErr)
+		}
+	}
+
+	logger.Info().Msg("http server stopped")
+
+	return err
+}
+
+// newAdminServer returns the server of the admin listener. It shares the
+// timeouts of the main server, except for the longer write timeout.
+func newAdminServer(o *serverOptions) *http.Server {
+	return &http.Server{
+		Handler:           o.adminHandler,
+		ReadTimeout:       o.readTimeout,
+		ReadHeaderTimeout: o.readHeaderTimeout,
+		WriteTimeout:      o.adminWriteTimeout,
+		IdleTimeout:       o.idleTimeout,
+		MaxHeaderBytes:    o.maxHeaderBytes,
+	}
+}
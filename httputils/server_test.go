@@ -0,0 +1,152 @@
+package httputils
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// freeAddr returns a loopback address with a port that was free a moment ago.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+// waitFor polls fn until it succeeds or a second has passed.
+func waitFor(t *testing.T, fn func() error) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := fn()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServe(t *testing.T) {
+	// Borrow the self-signed certificate and a client trusting it.
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsConfig, client := tlsServer.TLS, tlsServer.Client()
+	tlsServer.Close()
+
+	addr, adminAddr := freeAddr(t), freeAddr(t)
+	logger := zerolog.Nop()
+	var drained, hookCalled bool
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = io.WriteString(w, "main") }),
+			WithAddr(addr),
+			WithTLSConfig(tlsConfig),
+			WithAdminListener(adminAddr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, "admin")
+			})),
+			WithServerLogger(&logger),
+			WithReadinessDrain(func() { drained = true }),
+			WithDrainDelay(10*time.Millisecond),
+			WithShutdownHook(func(ctx context.Context) error {
+				hookCalled = true
+				return nil
+			}),
+		)
+	}()
+
+	waitFor(t, func() error {
+		body, err := get(client, "https://"+addr)
+		if err == nil && body != "main" {
+			t.Errorf("main listener body = %q", body)
+		}
+		return err
+	})
+	waitFor(t, func() error {
+		body, err := get(http.DefaultClient, "http://"+adminAddr)
+		if err == nil && body != "admin" {
+			t.Errorf("admin listener body = %q", body)
+		}
+		return err
+	})
+	if body, _ := get(http.DefaultClient, "http://"+addr); body == "main" {
+		t.Error("main listener served plain HTTP")
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+
+	if !drained {
+		t.Error("readiness probe was not drained")
+	}
+	if !hookCalled {
+		t.Error("shutdown hook was not called")
+	}
+	if _, err := get(http.DefaultClient, "http://"+adminAddr); err == nil {
+		t.Error("admin listener still serving after shutdown")
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	logger := zerolog.Nop()
+	if err := Serve(t.Context(), http.NotFoundHandler(), WithAddr(ln.Addr().String()), WithServerLogger(&logger)); err == nil {
+		t.Error("Serve succeeded on an address in use")
+	}
+}
+
+func TestAdminServerTimeouts(t *testing.T) {
+	o := defaultServerOptions()
+	for _, opt := range []ServerOption{WithReadTimeout(7 * time.Second), WithAdminWriteTimeout(90 * time.Second)} {
+		opt(&o)
+	}
+
+	srv := newAdminServer(&o)
+	if srv.ReadTimeout != 7*time.Second {
+		t.Errorf("ReadTimeout = %v, want 7s", srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != 90*time.Second {
+		t.Errorf("WriteTimeout = %v, want 90s", srv.WriteTimeout)
+	}
+	if srv.ReadHeaderTimeout == 0 || srv.IdleTimeout == 0 {
+		t.Error("ReadHeaderTimeout and IdleTimeout are not set")
+	}
+}
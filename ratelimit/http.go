@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JoinKeys combines the parts of a composite key, e.g. a client and a route,
+// for a per-client, per-endpoint quota. It returns an empty key if any part is
+// empty, which HTTP middleware treat as a request that is not limited.
+func JoinKeys(parts ...string) string {
+	for _, part := range parts {
+		if part == "" {
+			return ""
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// SetHeaders sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// response headers, the latter in whole seconds, and Retry-After when the
+// request was not allowed. For results of Allow, the reset is when the quota
+// next grows, e.g. when the next token arrives, rather than ResetAt.
+func (r *Result) SetHeaders(h http.Header) {
+	resetAt := r.resetAt
+	if r.retryAt != 0 {
+		resetAt = r.retryAt
+	}
+	reset := secondsUntil(time.Unix(0, resetAt))
+	h.Set("RateLimit-Limit", strconv.FormatInt(r.Total, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(r.Remaining, 10))
+	h.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(max(reset, 1), 10))
+	}
+}
+
+// secondsUntil rounds the time left until t up to whole seconds.
+func secondsUntil(t time.Time) int64 {
+	d := time.Until(t)
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJoinKeys(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"alice", "GET /users"}, "alice|GET /users"},
+		{[]string{"alice"}, "alice"},
+		{[]string{"", "GET /users"}, ""},
+		{[]string{"alice", ""}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := JoinKeys(tt.parts...); got != tt.want {
+			t.Errorf("JoinKeys(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestResultSetHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   map[string]string
+	}{
+		{
+			name:   "allowed",
+			result: Result{Allowed: true, Total: 10, Remaining: 4, resetAt: time.Now().Add(1500 * time.Millisecond).UnixNano()},
+			want:   map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "4", "RateLimit-Reset": "2", "Retry-After": ""},
+		},
+		{
+			name:   "denied",
+			result: Result{Total: 10, resetAt: time.Now().Add(30 * time.Second).UnixNano()},
+			want:   map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "30", "Retry-After": "30"},
+		},
+		{
+			name:   "denied until the next token",
+			result: Result{Total: 10, resetAt: time.Now().Add(10 * time.Second).UnixNano(), retryAt: time.Now().Add(time.Second).UnixNano()},
+			want:   map[string]string{"RateLimit-Reset": "1", "Retry-After": "1"},
+		},
+		{
+			name:   "denied with the reset already passed",
+			result: Result{Total: 10, resetAt: time.Now().Add(-time.Second).UnixNano()},
+			want:   map[string]string{"RateLimit-Reset": "0", "Retry-After": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			tt.result.SetHeaders(h)
+			for name, want := range tt.want {
+				if got := h.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ package ratelimit
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -25,6 +26,9 @@ type Result struct {
 	Remaining int64
 
 	resetAt int64
+	// retryAt is when the quota next grows, for the RateLimit-Reset and
+	// Retry-After headers. Zero means resetAt.
+	retryAt int64
 }
 
 // ResetAt returns the time at which the rate limit quota fully replenishes.
@@ -32,11 +36,90 @@ func (r *Result) ResetAt() time.Time {
 	return time.Unix(0, r.resetAt)
 }
 
+// Algorithm selects the rate limiting algorithm used by Allow.
+type Algorithm int
+
+const (
+	AlgorithmTokenBucket Algorithm = iota
+	AlgorithmLeakyBucket
+	AlgorithmSlidingWindow
+	AlgorithmFixedWindow
+	AlgorithmSlidingWindowCounter
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmTokenBucket:
+		return "token_bucket"
+	case AlgorithmLeakyBucket:
+		return "leaky_bucket"
+	case AlgorithmSlidingWindow:
+		return "sliding_window"
+	case AlgorithmFixedWindow:
+		return "fixed_window"
+	case AlgorithmSlidingWindowCounter:
+		return "sliding_window_counter"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+// Allow checks whether a request identified by key is allowed under the given
+// algorithm and returns the quota details. Unlike the per-algorithm methods,
+// which deny the request on Redis errors, Allow returns the error so that the
+// caller can decide whether to fail open or closed.
+func (config *RateLimit) Allow(ctx context.Context, algorithm Algorithm, key string) (*Result, error) {
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return config.tokenBucket(ctx, key)
+	case AlgorithmLeakyBucket:
+		return config.leakyBucket(ctx, key)
+	case AlgorithmSlidingWindow:
+		return config.slidingWindow(ctx, key)
+	case AlgorithmFixedWindow:
+		return config.fixedWindow(ctx, key)
+	case AlgorithmSlidingWindowCounter:
+		return config.slidingWindowCounter(ctx, key)
+	default:
+		return nil, fmt.Errorf("ratelimit: unknown algorithm %s", algorithm)
+	}
+}
+
+// newResult converts the {allowed, total, remaining, reset_at[, retry_at]}
+// reply shared by all scripts into a Result.
+func newResult(reply []int64) *Result {
+	res := &Result{
+		Allowed:   reply[0] == 1,
+		Total:     reply[1],
+		Remaining: reply[2],
+		resetAt:   reply[3],
+	}
+	if len(reply) > 4 {
+		res.retryAt = reply[4]
+	}
+	return res
+}
+
+// Ping reports whether the Redis backend is reachable, e.g. for readiness
+// probes.
+func (config *RateLimit) Ping(ctx context.Context) error {
+	return config.Redis.Ping(ctx).Err()
+}
+
 // TokenBucket checks whether a request identified by key is allowed under
 // token bucket rate limiting. Tokens refill at RefillRate per second up to
 // MaxRequests capacity, allowing short bursts. Returns a *Result with quota
 // details; on Redis error, returns denied.
 func (config *RateLimit) TokenBucket(ctx context.Context, key string) *Result {
+	result, err := config.tokenBucket(ctx, key)
+	if err != nil {
+		return &Result{Allowed: false, Total: int64(config.MaxRequests), Remaining: 0, resetAt: time.Now().UnixNano()}
+	}
+	return result
+}
+
+func (config *RateLimit) tokenBucket(ctx context.Context, key string) (*Result, error) {
 	key = "tb:" + key
 	now := time.Now().UnixNano()
 
@@ -59,21 +142,20 @@ func (config *RateLimit) TokenBucket(ctx context.Context, key string) *Result {
 			redis.call('HMSET', key, 'tokens', remaining, 'last_refill', now)
 			redis.call('EXPIRE', key, math.ceil(capacity / rate))
 		end
-		return {allowed, capacity, math.floor(remaining), reset_at}
+		local retry_at = now
+		if remaining < capacity then
+			retry_at = now + ((1 - remaining % 1) / rate * 1e9)
+		end
+		return {allowed, capacity, math.floor(remaining), reset_at, retry_at}
 	`
 
 	result, err := config.Redis.Eval(ctx, script, []string{key},
 		now, config.RefillRate, config.MaxRequests).Int64Slice()
 	if err != nil {
-		return &Result{Allowed: false, Total: int64(config.MaxRequests), Remaining: 0, resetAt: now}
+		return nil, err
 	}
 
-	return &Result{
-		Allowed:   result[0] == 1,
-		Total:     result[1],
-		Remaining: result[2],
-		resetAt:   result[3],
-	}
+	return newResult(result), nil
 }
 
 // LeakyBucket checks whether a request identified by key is allowed under
@@ -81,6 +163,11 @@ func (config *RateLimit) TokenBucket(ctx context.Context, key string) *Result {
 // rate, enforcing smooth throughput with no bursts. Returns true if allowed,
 // or false if the queue is full or a Redis error occurs.
 func (config *RateLimit) LeakyBucket(ctx context.Context, key string) bool {
+	result, err := config.leakyBucket(ctx, key)
+	return err == nil && result.Allowed
+}
+
+func (config *RateLimit) leakyBucket(ctx context.Context, key string) (*Result, error) {
 	key = "lb:" + key
 	now := time.Now().UnixNano()
 
@@ -94,20 +181,27 @@ func (config *RateLimit) LeakyBucket(ctx context.Context, key string) bool {
 		last_leak = tonumber(last_leak) or now
 		local leaked = math.floor((now - last_leak) * rate / 1e9)
 		queue = math.max(0, queue - leaked)
+		local allowed = 0
 		if queue < capacity then
-			redis.call('HMSET', key, 'queue', queue + 1, 'last_leak', now)
+			allowed = 1
+			queue = queue + 1
+			last_leak = now
+			redis.call('HMSET', key, 'queue', queue, 'last_leak', now)
 			redis.call('EXPIRE', key, math.ceil(capacity / rate))
-			return 1
 		end
-		return 0
+		local retry_at = now
+		if queue > 0 then
+			retry_at = last_leak + (1 / rate * 1e9)
+		end
+		return {allowed, capacity, capacity - queue, now + (queue / rate * 1e9), retry_at}
 	`
 
 	result, err := config.Redis.Eval(ctx, script, []string{key},
-		now, config.MaxRequests, config.MaxRequests).Int()
+		now, config.MaxRequests, config.MaxRequests).Int64Slice()
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return result == 1
+	return newResult(result), nil
 }
 
 // SlidingWindow checks whether a request identified by key is allowed under
@@ -115,6 +209,11 @@ func (config *RateLimit) LeakyBucket(ctx context.Context, key string) bool {
 // at most MaxRequests within any rolling Window. Returns true if allowed, or
 // false if the limit is reached or a Redis error occurs.
 func (config *RateLimit) SlidingWindow(ctx context.Context, key string) bool {
+	result, err := config.slidingWindow(ctx, key)
+	return err == nil && result.Allowed
+}
+
+func (config *RateLimit) slidingWindow(ctx context.Context, key string) (*Result, error) {
 	key = "sw:" + key
 	now := time.Now().UnixNano()
 	windowNanos := config.Window.Nanoseconds()
@@ -126,20 +225,32 @@ func (config *RateLimit) SlidingWindow(ctx context.Context, key string) bool {
 		local max_requests = tonumber(ARGV[3])
 		redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
 		local count = redis.call('ZCARD', key)
+		local allowed = 0
 		if count < max_requests then
+			allowed = 1
+			count = count + 1
 			redis.call('ZADD', key, now, now)
 			redis.call('EXPIRE', key, math.ceil(window / 1e9))
-			return 1
 		end
-		return 0
+		local reset_at = now + window
+		local retry_at = reset_at
+		local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+		if newest[2] then
+			reset_at = tonumber(newest[2]) + window
+		end
+		local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+		if oldest[2] then
+			retry_at = tonumber(oldest[2]) + window
+		end
+		return {allowed, max_requests, max_requests - count, reset_at, retry_at}
 	`
 
 	result, err := config.Redis.Eval(ctx, script, []string{key},
-		now, windowNanos, config.MaxRequests).Int()
+		now, windowNanos, config.MaxRequests).Int64Slice()
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return result == 1
+	return newResult(result), nil
 }
 
 // FixedWindow checks whether a request identified by key is allowed under
@@ -147,6 +258,11 @@ func (config *RateLimit) SlidingWindow(ctx context.Context, key string) bool {
 // windows of length Window, allowing up to MaxRequests per window. Bursts up
 // to 2x the limit are possible at window boundaries. Returns true if allowed.
 func (config *RateLimit) FixedWindow(ctx context.Context, key string) bool {
+	result, err := config.fixedWindow(ctx, key)
+	return err == nil && result.Allowed
+}
+
+func (config *RateLimit) fixedWindow(ctx context.Context, key string) (*Result, error) {
 	key = "fw:" + key
 	now := time.Now().UnixNano()
 	windowStart := (now / config.Window.Nanoseconds()) * config.Window.Nanoseconds()
@@ -159,20 +275,22 @@ func (config *RateLimit) FixedWindow(ctx context.Context, key string) bool {
 		local window_key = key .. ":" .. window_start
 		local count = redis.call('GET', window_key) or 0
 		count = tonumber(count)
+		local allowed = 0
 		if count < max_requests then
-			redis.call('INCR', window_key)
+			allowed = 1
+			count = redis.call('INCR', window_key)
 			redis.call('EXPIRE', window_key, window_seconds)
-			return 1
 		end
-		return 0
+		return {allowed, max_requests, max_requests - count, 0}
 	`
 
 	result, err := config.Redis.Eval(ctx, script, []string{key},
-		windowStart, config.MaxRequests, int(config.Window.Seconds())).Int()
+		windowStart, config.MaxRequests, int(config.Window.Seconds())).Int64Slice()
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return result == 1
+	result[3] = windowStart + config.Window.Nanoseconds()
+	return newResult(result), nil
 }
 
 // SlidingWindowCounter checks whether a request identified by key is allowed
@@ -180,6 +298,11 @@ func (config *RateLimit) FixedWindow(ctx context.Context, key string) bool {
 // window by weighting the current and previous fixed window counters, offering
 // a balance between accuracy and memory efficiency. Returns true if allowed.
 func (config *RateLimit) SlidingWindowCounter(ctx context.Context, key string) bool {
+	result, err := config.slidingWindowCounter(ctx, key)
+	return err == nil && result.Allowed
+}
+
+func (config *RateLimit) slidingWindowCounter(ctx context.Context, key string) (*Result, error) {
 	key = "swc:" + key
 	now := time.Now().UnixNano()
 	windowNanos := config.Window.Nanoseconds()
@@ -205,20 +328,23 @@ func (config *RateLimit) SlidingWindowCounter(ctx context.Context, key string) b
 		local weight = elapsed_in_current / window_nanos
 		local estimated_count = previous_count * (1 - weight) + current_count
 
+		local allowed = 0
 		if estimated_count < max_requests then
+			allowed = 1
+			estimated_count = estimated_count + 1
 			redis.call('INCR', current_key)
 			redis.call('EXPIRE', current_key, window_seconds * 2)
-			return 1
 		end
-		return 0
+		return {allowed, max_requests, math.max(0, math.floor(max_requests - estimated_count)), 0}
 	`
 
 	result, err := config.Redis.Eval(ctx, script, []string{key},
-		now, currentWindow, previousWindow, windowNanos, config.MaxRequests, int(config.Window.Seconds())).Int()
+		now, currentWindow, previousWindow, windowNanos, config.MaxRequests, int(config.Window.Seconds())).Int64Slice()
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return result == 1
+	result[3] = currentWindow + windowNanos
+	return newResult(result), nil
 }
 
 // DistributedSlidingWindow checks whether a request identified by key is
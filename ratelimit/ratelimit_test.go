@@ -79,9 +79,12 @@ func TestTokenBucketSleep(t *testing.T) {
 	key := fmt.Sprintf("%s-%d", t.Name(), time.Now().Unix())
 
 	synctest.Run(func() {
+		// t.Context was created outside the bubble and cannot be used in it.
+		ctx := context.Background()
+
 		for i := range 10 {
 			testName := fmt.Sprintf("%s-%d", key, i)
-			result := rl.TokenBucket(t.Context(), key)
+			result := rl.TokenBucket(ctx, key)
 			if !result.Allowed {
 				t.Fatalf("[%s] should have been allowed but rejected: %d", testName, i)
 			}
@@ -91,7 +94,7 @@ func TestTokenBucketSleep(t *testing.T) {
 
 		for i := range 10 {
 			testName := fmt.Sprintf("%s-%d", key, i)
-			result := rl.TokenBucket(t.Context(), key)
+			result := rl.TokenBucket(ctx, key)
 			if i < 3 {
 				if !result.Allowed {
 					t.Fatalf("[%s] should have been allowed but rejected: %d", testName, i)
@@ -105,6 +108,157 @@ func TestTokenBucketSleep(t *testing.T) {
 	})
 }
 
+func TestAllow(t *testing.T) {
+	tests := []struct {
+		algorithm Algorithm
+		// maxRetry bounds how far in the future the quota grows again once
+		// it is exhausted, which SetHeaders reports as RateLimit-Reset.
+		maxRetry time.Duration
+	}{
+		// The next token arrives after 1/RefillRate, not after a full refill.
+		{AlgorithmTokenBucket, time.Second},
+		// The queue drains MaxRequests slots per second.
+		{AlgorithmLeakyBucket, time.Second / 3},
+		{AlgorithmSlidingWindow, time.Minute},
+		{AlgorithmFixedWindow, time.Minute},
+		{AlgorithmSlidingWindowCounter, time.Minute},
+	}
+
+	rl := &RateLimit{
+		Redis:       redisClient,
+		MaxRequests: 3,
+		RefillRate:  1,
+		Window:      time.Minute,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm.String(), func(t *testing.T) {
+			key := fmt.Sprintf("%s-%d", t.Name(), time.Now().Unix())
+
+			for i := range 3 {
+				res, err := rl.Allow(t.Context(), tt.algorithm, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Allowed || res.Total != 3 || res.Remaining != int64(2-i) {
+					t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, res, 2-i)
+				}
+			}
+
+			start := time.Now()
+			res, err := rl.Allow(t.Context(), tt.algorithm, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Allowed || res.Remaining != 0 {
+				t.Fatalf("request 4 = %+v, want denied with 0 remaining", res)
+			}
+			retry := res.ResetAt()
+			if res.retryAt != 0 {
+				retry = time.Unix(0, res.retryAt)
+			}
+			if !retry.After(start) || retry.After(time.Now().Add(tt.maxRetry)) {
+				t.Errorf("quota grows again %v from now, want within %v", retry.Sub(start), tt.maxRetry)
+			}
+
+			other, err := rl.Allow(t.Context(), tt.algorithm, key+"-other")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !other.Allowed {
+				t.Error("quota is shared between keys")
+			}
+		})
+	}
+}
+
+func TestAllowSlidingWindowResetsFromOldest(t *testing.T) {
+	rl := &RateLimit{
+		Redis:       redisClient,
+		MaxRequests: 3,
+		Window:      time.Minute,
+	}
+
+	key := fmt.Sprintf("%s-%d", t.Name(), time.Now().Unix())
+
+	// A request logged 30s ago leaves the window 30s from now, well before
+	// the requests made below.
+	old := time.Now().Add(-30 * time.Second).UnixNano()
+	if err := redisClient.ZAdd(t.Context(), "sw:"+key, redis.Z{Score: float64(old), Member: old}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var res *Result
+	for range 3 {
+		var err error
+		if res, err = rl.Allow(t.Context(), AlgorithmSlidingWindow, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if res.Allowed {
+		t.Fatalf("request 3 = %+v, want denied", res)
+	}
+	if until := time.Until(time.Unix(0, res.retryAt)); until > 31*time.Second || until < 29*time.Second {
+		t.Errorf("quota grows again %v from now, want about 30s", until)
+	}
+	if until := time.Until(res.ResetAt()); until < 59*time.Second {
+		t.Errorf("ResetAt = %v from now, want a full window after the newest request", until)
+	}
+}
+
+func TestTokenBucketResetAt(t *testing.T) {
+	rl := &RateLimit{
+		Redis:       redisClient,
+		MaxRequests: 3,
+		RefillRate:  1,
+	}
+
+	key := fmt.Sprintf("%s-%d", t.Name(), time.Now().Unix())
+
+	synctest.Run(func() {
+		// t.Context was created outside the bubble and cannot be used in it.
+		ctx := context.Background()
+
+		var res *Result
+		for range 4 {
+			res = rl.TokenBucket(ctx, key)
+		}
+
+		// ResetAt of the denied request is when the empty bucket is full
+		// again, three tokens at one per second.
+		if until := time.Until(res.ResetAt()); until != 3*time.Second {
+			t.Errorf("ResetAt = %v from now, want 3s", until)
+		}
+	})
+}
+
+func TestAllowErrors(t *testing.T) {
+	rl := &RateLimit{
+		Redis:       redisClient,
+		MaxRequests: 3,
+		Window:      time.Minute,
+	}
+
+	if _, err := rl.Allow(t.Context(), Algorithm(42), "client"); err == nil {
+		t.Error("unknown algorithm: want an error")
+	}
+
+	down := redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	defer func() { _ = down.Close() }()
+	rl.Redis = down
+
+	if _, err := rl.Allow(t.Context(), AlgorithmFixedWindow, "client"); err == nil {
+		t.Error("Redis error: want an error")
+	}
+	if rl.FixedWindow(t.Context(), "client") {
+		t.Error("FixedWindow allowed the request on a Redis error")
+	}
+	if res := rl.TokenBucket(t.Context(), "client"); res.Allowed {
+		t.Error("TokenBucket allowed the request on a Redis error")
+	}
+}
+
 func BenchmarkTokenBucket(b *testing.B) {
 	rl := &RateLimit{
 		Redis:       redisClient,
@@ -0,0 +1,30 @@
+// Package ratelimittest provides a RateLimit backed by an in-memory Redis
+// server, for testing middleware built on the ratelimit package.
+package ratelimittest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/meysam81/x/ratelimit"
+)
+
+// New returns a RateLimit allowing maxRequests per minute, with token buckets
+// refilling one token per second. The in-memory Redis server is returned as
+// well; close it to simulate Redis being unavailable. Both are cleaned up when
+// the test ends.
+func New(t testing.TB, maxRequests int) (*ratelimit.RateLimit, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return &ratelimit.RateLimit{
+		Redis:       client,
+		MaxRequests: maxRequests,
+		RefillRate:  1,
+		Window:      time.Minute,
+	}, mr
+}
@@ -207,7 +207,23 @@ func (c *Client) SendEmail(ctx context.Context, email Email) error {
 	return fmt.Errorf("failed after %d attempts: %w", c.retryCount+1, lastErr)
 }
 
+// Ping connects to the server, negotiates TLS and authenticates without
+// sending a message, e.g. for readiness probes.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.withSession(ctx, func(client *smtp.Client) error {
+		return client.Noop()
+	})
+}
+
 func (c *Client) send(ctx context.Context, from string, to []string, msg []byte) error {
+	return c.withSession(ctx, func(client *smtp.Client) error {
+		return c.sendMessage(client, from, to, msg)
+	})
+}
+
+// withSession connects and authenticates to the server, runs fn on the
+// session and closes the connection.
+func (c *Client) withSession(ctx context.Context, fn func(client *smtp.Client) error) error {
 	addr := net.JoinHostPort(c.host, fmt.Sprintf("%d", c.port))
 
 	conn, client, err := c.establishConnection(ctx, addr)
@@ -227,7 +243,7 @@ func (c *Client) send(ctx context.Context, from string, to []string, msg []byte)
 		return err
 	}
 
-	return c.sendMessage(client, from, to, msg)
+	return fn(client)
 }
 
 func (c *Client) establishConnection(ctx context.Context, addr string) (net.Conn, *smtp.Client, error) {
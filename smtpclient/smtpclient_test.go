@@ -1,9 +1,13 @@
 package smtpclient
 
 import (
+	"bufio"
 	"bytes"
+	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +52,87 @@ func TestSendEmailWorks(t *testing.T) {
 		t.Fatalf("failed sending email: %s", err)
 	}
 }
+
+// fakeSMTPServer accepts a single connection and answers NOOP with
+// noopReply, which is enough for Ping without TLS or authentication.
+func fakeSMTPServer(t *testing.T, noopReply string) (string, int) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, _ = fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			verb, _, _ := strings.Cut(scanner.Text(), " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				_, _ = fmt.Fprint(conn, "250 localhost\r\n")
+			case "NOOP":
+				_, _ = fmt.Fprint(conn, noopReply+"\r\n")
+			case "QUIT":
+				_, _ = fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				_, _ = fmt.Fprint(conn, "502 not implemented\r\n")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name      string
+		noopReply string
+		wantErr   bool
+	}{
+		{"server ready", "250 OK", false},
+		{"server unavailable", "421 shutting down", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port := fakeSMTPServer(t, tt.noopReply)
+
+			clnt, err := New(host, port, "", "", WithoutTLS())
+			if err != nil {
+				t.Fatalf("client init error: %s", err)
+			}
+
+			if err := clnt.Ping(t.Context()); (err != nil) != tt.wantErr {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPingConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	clnt, err := New("127.0.0.1", port, "", "", WithoutTLS())
+	if err != nil {
+		t.Fatalf("client init error: %s", err)
+	}
+
+	if err := clnt.Ping(t.Context()); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("Ping() error = %v, want connection failure", err)
+	}
+}